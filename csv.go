@@ -0,0 +1,265 @@
+package sutils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrQuote is returned when a quoted field of a CSV file is not closed by
+// the end of the input.
+var ErrQuote = errors.New("unterminated quoted field")
+
+// CSVRow is a logical row of a CSV file. A row spans more than one line when
+// one of its quoted fields contains a newline.
+type CSVRow struct {
+	// Number is the number of the row, counting from 1. The header is row 1.
+	Number int
+
+	// Line is the number of the line the row starts on, counting from 1.
+	Line int
+
+	// Fields are the values of the row, with quoting removed.
+	Fields []string
+
+	// Raw is the row as it was written, without its line ending. Lines
+	// within a quoted field are joined by "\n".
+	Raw string
+}
+
+// CSVReader reads the logical rows of a CSV file as described in RFC 4180:
+// fields separated by a comma, or another separator, and optionally quoted
+// with double quotes. A double quote in a quoted field is written twice.
+// TSV files are read with a tab as the separator.
+type CSVReader struct {
+	lr    *LineReader
+	comma string
+
+	row CSVRow
+	err error
+}
+
+// NewCSVReader returns a CSVReader reading from r, with fields separated by
+// comma. Like the Find functions, it transcodes r to UTF-8, unless r is a
+// *LineReader.
+func NewCSVReader(r io.Reader, comma rune) *CSVReader {
+	return &CSVReader{lr: lineReader(r), comma: string(comma)}
+}
+
+// Scan advances to the next row, which is then available through Row. It
+// returns false at the end of the input or on an error, which is then
+// returned by Err.
+func (c *CSVReader) Scan() bool {
+	if c.err != nil || !c.lr.Scan() {
+		return false
+	}
+
+	line, start, offset := c.lr.Text(), c.lr.Line(), c.lr.Offset()
+	raw := line
+
+	// A line that ends inside a quoted field goes on on the next line.
+	open := c.quoteOpen(line, false)
+	for open {
+		if !c.lr.Scan() {
+			if c.lr.Err() == nil {
+				c.err = &LineError{Op: OpRead, Line: start, Offset: offset, Err: ErrQuote}
+			}
+
+			return false
+		}
+
+		line = c.lr.Text()
+		raw += "\n" + line
+		open = c.quoteOpen(line, true)
+	}
+
+	c.row = CSVRow{Number: c.row.Number + 1, Line: start, Fields: splitCSV(raw, c.comma), Raw: raw}
+
+	return true
+}
+
+// quoteOpen reports whether line ends inside a quoted field, given whether
+// it starts inside one. Only a quote at the start of a field opens one.
+func (c *CSVReader) quoteOpen(line string, open bool) bool {
+	fieldStart := !open
+
+	for i := 0; i < len(line); i++ {
+		switch {
+		case open && line[i] == '"':
+			if i+1 < len(line) && line[i+1] == '"' {
+				i++
+			} else {
+				open = false
+			}
+		case open:
+			// Anything else in a quoted field is part of its value.
+		case fieldStart && line[i] == '"':
+			open = true
+		case strings.HasPrefix(line[i:], c.comma):
+			fieldStart = true
+			i += len(c.comma) - 1
+
+			continue
+		}
+
+		fieldStart = false
+	}
+
+	return open
+}
+
+// splitCSV splits a row into its fields and removes their quoting. Quotes in
+// the middle of an unquoted field are kept as they are.
+func splitCSV(raw, comma string) []string {
+	var fields []string
+
+	for {
+		if !strings.HasPrefix(raw, `"`) {
+			i := strings.Index(raw, comma)
+			if i < 0 {
+				return append(fields, raw)
+			}
+
+			fields = append(fields, raw[:i])
+			raw = raw[i+len(comma):]
+
+			continue
+		}
+
+		var sb strings.Builder
+
+		i := 1
+		for i < len(raw) {
+			if raw[i] != '"' {
+				sb.WriteByte(raw[i])
+				i++
+
+				continue
+			}
+
+			if strings.HasPrefix(raw[i:], `""`) {
+				sb.WriteByte('"')
+				i += 2
+
+				continue
+			}
+
+			i++
+
+			break
+		}
+
+		// Anything between the closing quote and the separator belongs to
+		// the field as well.
+		rest := raw[i:]
+		j := strings.Index(rest, comma)
+		if j < 0 {
+			return append(fields, sb.String()+rest)
+		}
+
+		fields = append(fields, sb.String()+rest[:j])
+		raw = rest[j+len(comma):]
+	}
+}
+
+// Row returns the current row.
+func (c *CSVReader) Row() CSVRow {
+	return c.row
+}
+
+// Err returns the first error encountered by Scan as a *LineError, or nil
+// if the input was read to its end.
+func (c *CSVReader) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+
+	return c.lr.Err()
+}
+
+// ColumnIndex returns the index of column in header. The column is looked
+// up by name first; if no column has that name and it is a number, it is
+// taken as an index, counting from 0.
+func ColumnIndex(header []string, column string) (int, error) {
+	for i, name := range header {
+		if name == column {
+			return i, nil
+		}
+	}
+
+	if i, err := strconv.Atoi(column); err == nil && i >= 0 && i < len(header) {
+		return i, nil
+	}
+
+	return 0, fmt.Errorf("no column %q", column)
+}
+
+// FindInColumn returns the numbers of the rows of the CSV file haystack
+// whose value in column contains one of the needles according to find, like
+// FindWith does for lines. The first row is the header, which is used to
+// look up column, see ColumnIndex, and is never matched. Rows are numbered
+// as by CSVReader, and can be passed on to CopyRows.
+func FindInColumn(find func(string, string) bool, haystack io.Reader, comma rune, column string, needles []string) ([]int, error) {
+	rows := make([]int, 0)
+
+	c := NewCSVReader(haystack, comma)
+	if !c.Scan() {
+		return rows, c.Err()
+	}
+
+	col, err := ColumnIndex(c.Row().Fields, column)
+	if err != nil {
+		return nil, err
+	}
+
+	if needles[0] == "" {
+		return rows, nil
+	}
+
+	for c.Scan() {
+		row := c.Row()
+		if col >= len(row.Fields) {
+			continue
+		}
+
+		for _, needle := range needles {
+			if find(row.Fields[col], needle) {
+				rows = append(rows, row.Number)
+				break
+			}
+		}
+	}
+
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// CopyRows copies the header and the rows specified in "rows" of the CSV file
+// "from" to the io.Writer "to", as they were written. Every row is ended with
+// "\n".
+func CopyRows(from io.Reader, comma rune, rows []int, to io.Writer) error {
+	rowMap := make(map[int]bool)
+
+	for _, r := range rows {
+		rowMap[r] = true
+	}
+
+	c := NewCSVReader(from, comma)
+	for c.Scan() {
+		row := c.Row()
+		if row.Number != 1 && !rowMap[row.Number] {
+			continue
+		}
+
+		if _, err := io.WriteString(to, row.Raw+"\n"); err != nil {
+			return &LineError{Op: OpWrite, Line: row.Line, Err: err}
+		}
+	}
+
+	return c.Err()
+}
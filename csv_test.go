@@ -0,0 +1,125 @@
+package sutils
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const exportCSV = `id,name,comment
+1,alice,"likes ""go"", and tea"
+2,bob,"line one
+line two, with comma"
+3,carol,plain
+4,"dave, jr",go
+`
+
+func TestCSVReader(t *testing.T) {
+	c := NewCSVReader(strings.NewReader(exportCSV), ',')
+
+	var rows []CSVRow
+	for c.Scan() {
+		rows = append(rows, c.Row())
+	}
+
+	if err := c.Err(); err != nil {
+		t.Fatalf("CSVReader errored out: %v", err)
+	}
+
+	expected := []CSVRow{
+		{1, 1, []string{"id", "name", "comment"}, "id,name,comment"},
+		{2, 2, []string{"1", "alice", `likes "go", and tea`}, `1,alice,"likes ""go"", and tea"`},
+		{3, 3, []string{"2", "bob", "line one\nline two, with comma"}, "2,bob,\"line one\nline two, with comma\""},
+		{4, 5, []string{"3", "carol", "plain"}, "3,carol,plain"},
+		{5, 6, []string{"4", "dave, jr", "go"}, `4,"dave, jr",go`},
+	}
+
+	if !reflect.DeepEqual(expected, rows) {
+		t.Errorf("rows mismatch.\nExpected %+v\ngot      %+v", expected, rows)
+	}
+}
+
+func TestCSVReaderFields(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Comma  rune
+		Fields []string
+	}{
+		{"a,b,c", ',', []string{"a", "b", "c"}},
+		{"a,,", ',', []string{"a", "", ""}},
+		{`"",x`, ',', []string{"", "x"}},
+		{`a"b,c`, ',', []string{`a"b`, "c"}},
+		{"a\tb c\t\"d\te\"", '\t', []string{"a", "b c", "d\te"}},
+		{"ä;\"ö\";ü", ';', []string{"ä", "ö", "ü"}},
+		{"", ',', []string{""}},
+	}
+
+	for _, test := range tests {
+		c := NewCSVReader(strings.NewReader(test.Input+"\n"), test.Comma)
+		if !c.Scan() {
+			t.Errorf("%q: no row read: %v", test.Input, c.Err())
+			continue
+		}
+
+		if got := c.Row().Fields; !reflect.DeepEqual(test.Fields, got) {
+			t.Errorf("%q: fields mismatch. Expected %q, got %q", test.Input, test.Fields, got)
+		}
+	}
+}
+
+func TestCSVReaderUnterminatedQuote(t *testing.T) {
+	c := NewCSVReader(strings.NewReader("a,b\n1,\"open\nstill open\n"), ',')
+	for c.Scan() {
+	}
+
+	var lerr *LineError
+	if !errors.As(c.Err(), &lerr) || !errors.Is(lerr, ErrQuote) || lerr.Line != 2 {
+		t.Errorf("expected ErrQuote on line 2, got %v", c.Err())
+	}
+}
+
+func TestFindInColumn(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Column  string
+		Needles []string
+		Rows    []int
+	}{
+		{"by name", "comment", []string{"go"}, []int{2, 5}},
+		{"by index", "2", []string{"go"}, []int{2, 5}},
+		{"multi-line cell", "comment", []string{"line two"}, []int{3}},
+		{"quoted separator", "name", []string{","}, []int{5}},
+		{"header not matched", "name", []string{"name"}, []int{}},
+		{"empty needle", "name", []string{""}, []int{}},
+	}
+
+	for _, test := range tests {
+		rows, err := FindInColumn(strings.Contains, strings.NewReader(exportCSV), ',', test.Column, test.Needles)
+		if err != nil {
+			t.Errorf("%s: FindInColumn errored out: %v", test.Name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(test.Rows, rows) {
+			t.Errorf("%s: rows mismatch. Expected %v, got %v", test.Name, test.Rows, rows)
+		}
+	}
+
+	if _, err := FindInColumn(strings.Contains, strings.NewReader(exportCSV), ',', "missing", []string{"x"}); err == nil {
+		t.Errorf("expected an error for a missing column")
+	}
+}
+
+func TestCopyRows(t *testing.T) {
+	var buf bytes.Buffer
+	if err := CopyRows(strings.NewReader(exportCSV), ',', []int{3, 5}, &buf); err != nil {
+		t.Fatalf("CopyRows errored out: %v", err)
+	}
+
+	expected := "id,name,comment\n2,bob,\"line one\nline two, with comma\"\n4,\"dave, jr\",go\n"
+	if buf.String() != expected {
+		t.Errorf("CopyRows mismatch.\nExpected %q\ngot      %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,373 @@
+package sutils
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// KeyFunc maps a line to the key it is compared by when deduplicating and
+// counting lines. A nil KeyFunc compares lines as they are.
+type KeyFunc func(line string) string
+
+// IgnoreCaseKey compares lines in a case-insensitive way.
+func IgnoreCaseKey(line string) string {
+	return strings.ToLower(line)
+}
+
+// NormalizeSpaceKey compares lines ignoring leading and trailing whitespace,
+// and treating every run of whitespace in them as a single space.
+func NormalizeSpaceKey(line string) string {
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// CombineKeys returns a KeyFunc that applies keys one after the other.
+func CombineKeys(keys ...KeyFunc) KeyFunc {
+	return func(line string) string {
+		for _, key := range keys {
+			line = key(line)
+		}
+
+		return line
+	}
+}
+
+func (k KeyFunc) of(line string) string {
+	if k == nil {
+		return line
+	}
+
+	return k(line)
+}
+
+// Keep says which of a set of duplicate lines is kept.
+type Keep int
+
+// Which of a set of duplicate lines to keep.
+const (
+	KeepFirst Keep = iota
+	KeepLast
+)
+
+// UniqueLines returns the numbers of the lines of "from" that are kept when
+// removing duplicate lines, as compared by key, in order. They can be passed
+// on to CopyLines.
+func UniqueLines(from io.Reader, key KeyFunc, keep Keep) ([]int, error) {
+	r := lineReader(from)
+	kept := make(map[string]int)
+
+	var lines []int
+	for r.Scan() {
+		k := key.of(r.Text())
+
+		if _, ok := kept[k]; ok && keep == KeepFirst {
+			continue
+		}
+
+		kept[k] = r.Line()
+
+		if keep == KeepFirst {
+			lines = append(lines, r.Line())
+		}
+	}
+
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	if keep == KeepLast {
+		for _, l := range kept {
+			lines = append(lines, l)
+		}
+
+		sort.Ints(lines)
+	}
+
+	return lines, nil
+}
+
+// CopyUnique copies the lines of "from" to "to" without duplicates, as
+// compared by key, like CopyLines does. With KeepFirst the lines are copied
+// as they are read; with KeepLast nothing is copied until all of "from" has
+// been read, and every distinct line is held in memory until then.
+func CopyUnique(from io.Reader, key KeyFunc, keep Keep, to io.Writer) error {
+	r := lineReader(from)
+
+	if keep == KeepFirst {
+		seen := make(map[string]bool)
+
+		for r.Scan() {
+			k := key.of(r.Text())
+			if seen[k] {
+				continue
+			}
+
+			seen[k] = true
+
+			if err := writeLine(to, r); err != nil {
+				return err
+			}
+		}
+
+		return r.Err()
+	}
+
+	type last struct {
+		line int
+		text string
+	}
+
+	kept := make(map[string]last)
+	for r.Scan() {
+		kept[key.of(r.Text())] = last{r.Line(), r.Text()}
+	}
+
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	lines := make([]last, 0, len(kept))
+	for _, l := range kept {
+		lines = append(lines, l)
+	}
+
+	sort.Slice(lines, func(i, j int) bool { return lines[i].line < lines[j].line })
+
+	for _, l := range lines {
+		if _, err := io.WriteString(to, l.text+"\n"); err != nil {
+			return &LineError{Op: OpWrite, Line: l.line, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// LineCount is a line and how often it occurs.
+type LineCount struct {
+	// Text is the line, as it was first read, without its line ending.
+	Text string
+
+	// Count is how often the line occurs.
+	Count int
+}
+
+// CopyUniq collapses runs of adjacent equal lines, as compared by key, into
+// their first line, the way "uniq" does, and writes them to "to". With
+// withCount every line is preceded by the length of its run, the way
+// "uniq -c" does.
+func CopyUniq(from io.Reader, key KeyFunc, withCount bool, to io.Writer) error {
+	r := lineReader(from)
+
+	var (
+		run     LineCount
+		runKey  string
+		runLine int
+	)
+
+	flush := func() error {
+		if run.Count == 0 {
+			return nil
+		}
+
+		out := run.Text + "\n"
+		if withCount {
+			out = fmt.Sprintf("%7d %s", run.Count, out)
+		}
+
+		if _, err := io.WriteString(to, out); err != nil {
+			return &LineError{Op: OpWrite, Line: runLine, Err: err}
+		}
+
+		return nil
+	}
+
+	for r.Scan() {
+		k := key.of(r.Text())
+		if run.Count > 0 && k == runKey {
+			run.Count++
+			continue
+		}
+
+		if err := flush(); err != nil {
+			return err
+		}
+
+		run, runKey, runLine = LineCount{Text: r.Text(), Count: 1}, k, r.Line()
+	}
+
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+// TopK returns the k most frequent lines of "from", as compared by key, most
+// frequent first. Lines that are equally frequent are in the order they first
+// occur in. Every distinct line is held in memory; ApproxTopK is for inputs
+// with more of them than fit.
+func TopK(from io.Reader, key KeyFunc, k int) ([]LineCount, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+
+	r := lineReader(from)
+
+	index := make(map[string]int)
+
+	var counts []LineCount
+	for r.Scan() {
+		kk := key.of(r.Text())
+
+		i, ok := index[kk]
+		if !ok {
+			i = len(counts)
+			index[kk] = i
+			counts = append(counts, LineCount{Text: r.Text()})
+		}
+
+		counts[i].Count++
+	}
+
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(counts, func(i, j int) bool { return counts[i].Count > counts[j].Count })
+
+	if len(counts) > k {
+		counts = counts[:k]
+	}
+
+	return counts, nil
+}
+
+// Sizes of the sketches used by ApproxTopK and ApproxCountDistinct.
+const (
+	approxSketchWidth = 1 << 16
+	approxSketchDepth = 5
+	approxPrecision   = 14
+)
+
+// ApproxTopK works like TopK, but counts lines in a CountMinSketch and keeps
+// only k candidates in memory. The counts it returns may be too high, and
+// lines just about as frequent as the k-th one may be missed.
+func ApproxTopK(from io.Reader, key KeyFunc, k int) ([]LineCount, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+
+	r := lineReader(from)
+	sketch := NewCountMinSketch(approxSketchWidth, approxSketchDepth)
+
+	top := &candidates{index: make(map[string]int)}
+	for r.Scan() {
+		kk := key.of(r.Text())
+
+		sketch.Add(kk)
+		count := int(sketch.Count(kk))
+
+		if i, ok := top.index[kk]; ok {
+			top.items[i].Count = count
+			heap.Fix(top, i)
+
+			continue
+		}
+
+		if top.Len() < k {
+			heap.Push(top, candidate{key: kk, LineCount: LineCount{Text: r.Text(), Count: count}})
+			continue
+		}
+
+		if count > top.items[0].Count {
+			delete(top.index, top.items[0].key)
+			top.items[0] = candidate{key: kk, LineCount: LineCount{Text: r.Text(), Count: count}}
+			top.index[kk] = 0
+			heap.Fix(top, 0)
+		}
+	}
+
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	counts := make([]LineCount, top.Len())
+	for i := len(counts) - 1; i >= 0; i-- {
+		counts[i] = heap.Pop(top).(candidate).LineCount
+	}
+
+	return counts, nil
+}
+
+// candidate is a line ApproxTopK counts among the most frequent ones.
+type candidate struct {
+	LineCount
+	key string
+}
+
+// candidates is a min-heap of candidates by count, which keeps track of
+// where every candidate is in it.
+type candidates struct {
+	items []candidate
+	index map[string]int
+}
+
+func (c *candidates) Len() int           { return len(c.items) }
+func (c *candidates) Less(i, j int) bool { return c.items[i].Count < c.items[j].Count }
+
+func (c *candidates) Swap(i, j int) {
+	c.items[i], c.items[j] = c.items[j], c.items[i]
+	c.index[c.items[i].key] = i
+	c.index[c.items[j].key] = j
+}
+
+func (c *candidates) Push(x interface{}) {
+	item := x.(candidate)
+	c.index[item.key] = len(c.items)
+	c.items = append(c.items, item)
+}
+
+func (c *candidates) Pop() interface{} {
+	item := c.items[len(c.items)-1]
+	c.items = c.items[:len(c.items)-1]
+	delete(c.index, item.key)
+
+	return item
+}
+
+// CountDistinct returns the number of distinct lines of "from", as compared
+// by key.
+func CountDistinct(from io.Reader, key KeyFunc) (int, error) {
+	r := lineReader(from)
+	seen := make(map[string]bool)
+
+	for r.Scan() {
+		seen[key.of(r.Text())] = true
+	}
+
+	if err := r.Err(); err != nil {
+		return 0, err
+	}
+
+	return len(seen), nil
+}
+
+// ApproxCountDistinct estimates the number of distinct lines of "from", as
+// compared by key, with a HyperLogLog. Its standard error is under 1%, and it
+// uses 16KB of memory however many lines there are.
+func ApproxCountDistinct(from io.Reader, key KeyFunc) (uint64, error) {
+	r := lineReader(from)
+	hll := NewHyperLogLog(approxPrecision)
+
+	for r.Scan() {
+		hll.Add(key.of(r.Text()))
+	}
+
+	if err := r.Err(); err != nil {
+		return 0, err
+	}
+
+	return hll.Estimate(), nil
+}
@@ -0,0 +1,183 @@
+package sutils
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const dupes = `b
+a
+B
+ a
+b
+c
+`
+
+func TestUniqueLines(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Key   KeyFunc
+		Keep  Keep
+		Lines []int
+	}{
+		{"exact first", nil, KeepFirst, []int{1, 2, 3, 4, 6}},
+		{"exact last", nil, KeepLast, []int{2, 3, 4, 5, 6}},
+		{"ignore case", IgnoreCaseKey, KeepFirst, []int{1, 2, 4, 6}},
+		{"ignore case last", IgnoreCaseKey, KeepLast, []int{2, 4, 5, 6}},
+		{"normalize space", NormalizeSpaceKey, KeepFirst, []int{1, 2, 3, 6}},
+		{"both", CombineKeys(IgnoreCaseKey, NormalizeSpaceKey), KeepLast, []int{4, 5, 6}},
+	}
+
+	for _, test := range tests {
+		lines, err := UniqueLines(strings.NewReader(dupes), test.Key, test.Keep)
+		if err != nil {
+			t.Errorf("%s: UniqueLines errored out: %v", test.Name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(test.Lines, lines) {
+			t.Errorf("%s: lines mismatch. Expected %v, got %v", test.Name, test.Lines, lines)
+		}
+
+		var expected, got bytes.Buffer
+		if err := CopyLines(strings.NewReader(dupes), lines, &expected); err != nil {
+			t.Fatalf("%s: CopyLines errored out: %v", test.Name, err)
+		}
+
+		if err := CopyUnique(strings.NewReader(dupes), test.Key, test.Keep, &got); err != nil {
+			t.Errorf("%s: CopyUnique errored out: %v", test.Name, err)
+		}
+
+		if got.String() != expected.String() {
+			t.Errorf("%s: CopyUnique mismatch. Expected %q, got %q", test.Name, expected.String(), got.String())
+		}
+	}
+}
+
+func TestCopyUniq(t *testing.T) {
+	input := "a\na\nA\nb\na\na\na\n"
+
+	tests := []struct {
+		Key       KeyFunc
+		WithCount bool
+		Expected  string
+	}{
+		{nil, false, "a\nA\nb\na\n"},
+		{nil, true, "      2 a\n      1 A\n      1 b\n      3 a\n"},
+		{IgnoreCaseKey, true, "      3 a\n      1 b\n      3 a\n"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := CopyUniq(strings.NewReader(input), test.Key, test.WithCount, &buf); err != nil {
+			t.Errorf("CopyUniq errored out: %v", err)
+			continue
+		}
+
+		if buf.String() != test.Expected {
+			t.Errorf("CopyUniq(count: %v) mismatch. Expected %q, got %q", test.WithCount, test.Expected, buf.String())
+		}
+	}
+}
+
+func TestTopK(t *testing.T) {
+	input := "x\ny\nz\ny\nZ\ny\nz\n"
+
+	counts, err := TopK(strings.NewReader(input), nil, 2)
+	if err != nil {
+		t.Fatalf("TopK errored out: %v", err)
+	}
+
+	expected := []LineCount{{"y", 3}, {"z", 2}}
+	if !reflect.DeepEqual(expected, counts) {
+		t.Errorf("TopK mismatch. Expected %v, got %v", expected, counts)
+	}
+
+	counts, err = TopK(strings.NewReader(input), IgnoreCaseKey, 5)
+	if err != nil {
+		t.Fatalf("TopK errored out: %v", err)
+	}
+
+	expected = []LineCount{{"y", 3}, {"z", 3}, {"x", 1}}
+	if !reflect.DeepEqual(expected, counts) {
+		t.Errorf("TopK ignoring case mismatch. Expected %v, got %v", expected, counts)
+	}
+
+	for _, k := range []int{0, -1} {
+		if counts, err := TopK(strings.NewReader(input), nil, k); err != nil || len(counts) != 0 {
+			t.Errorf("TopK with k = %d returned %v, %v; expected nothing", k, counts, err)
+		}
+	}
+}
+
+// zipfLines returns lines "item i", occurring n/(8i) times each, followed
+// by n/4 distinct "rare" lines.
+func zipfLines(n int) string {
+	var sb strings.Builder
+
+	for i := 1; i <= 1000; i++ {
+		for j := 0; j < n/(i*8); j++ {
+			fmt.Fprintf(&sb, "item %d\n", i)
+		}
+	}
+
+	for i := 0; i < n/4; i++ {
+		fmt.Fprintf(&sb, "rare %d\n", i)
+	}
+
+	return sb.String()
+}
+
+func TestApproxTopK(t *testing.T) {
+	input := zipfLines(100000)
+
+	exact, err := TopK(strings.NewReader(input), nil, 5)
+	if err != nil {
+		t.Fatalf("TopK errored out: %v", err)
+	}
+
+	approx, err := ApproxTopK(strings.NewReader(input), nil, 5)
+	if err != nil {
+		t.Fatalf("ApproxTopK errored out: %v", err)
+	}
+
+	if len(approx) != len(exact) {
+		t.Fatalf("ApproxTopK returned %d lines, expected %d", len(approx), len(exact))
+	}
+
+	for i := range exact {
+		if approx[i].Text != exact[i].Text {
+			t.Errorf("line %d: expected %q, got %q", i, exact[i].Text, approx[i].Text)
+		}
+
+		if approx[i].Count < exact[i].Count || approx[i].Count > exact[i].Count+exact[i].Count/100 {
+			t.Errorf("%q: count %d too far from %d", exact[i].Text, approx[i].Count, exact[i].Count)
+		}
+	}
+}
+
+func TestApproxCountDistinct(t *testing.T) {
+	input := zipfLines(100000)
+
+	exact, err := CountDistinct(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatalf("CountDistinct errored out: %v", err)
+	}
+
+	approx, err := ApproxCountDistinct(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatalf("ApproxCountDistinct errored out: %v", err)
+	}
+
+	if diff := float64(approx) - float64(exact); diff > float64(exact)*0.03 || -diff > float64(exact)*0.03 {
+		t.Errorf("ApproxCountDistinct = %d, exact count is %d", approx, exact)
+	}
+
+	distinct, err := CountDistinct(strings.NewReader(dupes), IgnoreCaseKey)
+	if err != nil || distinct != 4 {
+		t.Errorf("CountDistinct ignoring case = %d, %v; expected 4", distinct, err)
+	}
+}
@@ -0,0 +1,114 @@
+package sutils
+
+import (
+	"strings"
+
+	"github.com/icrowley/fake"
+)
+
+// Dialect describes what a database accepts as unquoted names of users and
+// of databases, tables and the like.
+type Dialect struct {
+	// Name names the dialect, e.g. "postgres".
+	Name string
+
+	// MaxUserLength and MaxNameLength are the most characters user names
+	// and other names can have.
+	MaxUserLength, MaxNameLength int
+
+	// Extra are the characters names may have besides ASCII letters, digits
+	// and underscores.
+	Extra string
+
+	// Upper is true for dialects that fold unquoted names to upper case,
+	// rather than lower case.
+	Upper bool
+}
+
+// The supported dialects.
+var (
+	// MySQL limits user names to 16 characters, the limit before 5.7.8,
+	// so they work with every version.
+	MySQL = Dialect{Name: "mysql", MaxUserLength: 16, MaxNameLength: 64, Extra: "$"}
+
+	Postgres = Dialect{Name: "postgres", MaxUserLength: 63, MaxNameLength: 63, Extra: "$"}
+
+	// Oracle is Oracle before 12.2, Oracle12 is 12.2 and later.
+	Oracle   = Dialect{Name: "oracle", MaxUserLength: 30, MaxNameLength: 30, Extra: "$#", Upper: true}
+	Oracle12 = Dialect{Name: "oracle12", MaxUserLength: 128, MaxNameLength: 128, Extra: "$#", Upper: true}
+
+	SQLServer = Dialect{Name: "sqlserver", MaxUserLength: 128, MaxNameLength: 128, Extra: "$#@"}
+)
+
+// SanitizeUserName turns name into a user name the dialect accepts, see
+// SanitizeName.
+func (d Dialect) SanitizeUserName(name string) string {
+	return d.sanitize(name, d.MaxUserLength)
+}
+
+// SanitizeName turns name into a name of a database, table and the like that
+// the dialect accepts unquoted: characters it does not allow become
+// underscores, runs of underscores become one, names not starting with a
+// letter get an "n" in front, the case is the one the dialect folds names
+// to, and names too long are cut short. The result never starts or ends
+// with an underscore.
+func (d Dialect) SanitizeName(name string) string {
+	return d.sanitize(name, d.MaxNameLength)
+}
+
+func (d Dialect) sanitize(name string, max int) string {
+	var sb strings.Builder
+
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c < 0x80 && strings.ContainsRune(d.Extra, c):
+		default:
+			c = '_'
+		}
+
+		if c == '_' && strings.HasSuffix(sb.String(), "_") {
+			continue
+		}
+
+		sb.WriteRune(c)
+	}
+
+	res := strings.TrimLeft(sb.String(), "_")
+	if res == "" || !isASCIILetter(res[0]) {
+		res = "n" + res
+	}
+
+	if d.Upper {
+		res = strings.ToUpper(res)
+	} else {
+		res = strings.ToLower(res)
+	}
+
+	if len(res) > max {
+		res = res[:max]
+	}
+
+	return strings.TrimRight(res, "_")
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// randWords returns the first two words of a random product name, joined
+// by an underscore.
+func randWords() string {
+	return strings.Join(strings.Split(fake.ProductName(), " ")[:2], "_")
+}
+
+// RandUserName returns a random user name the dialect accepts.
+func (d Dialect) RandUserName() string {
+	return d.SanitizeUserName(randWords())
+}
+
+// RandName returns a random name of a database, table and the like that the
+// dialect accepts.
+func (d Dialect) RandName() string {
+	return d.SanitizeName(randWords())
+}
@@ -0,0 +1,50 @@
+package sutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		Dialect  Dialect
+		Name     string
+		Expected string
+	}{
+		{MySQL, "Awesome Granite", "awesome_granite"},
+		{MySQL, "Ergonomic  --Steel", "ergonomic_steel"},
+		{MySQL, "__price$", "price$"},
+		{MySQL, "42 tables", "n42_tables"},
+		{MySQL, "!!!", "n"},
+		{MySQL, "árvíztűrő", "rv_zt_r"},
+		{Postgres, "Small Wooden", "small_wooden"},
+		{Oracle, "Small Wooden#1", "SMALL_WOODEN#1"},
+		{SQLServer, "@team Name", "n@team_name"},
+		{Postgres, "a_" + strings.Repeat("b", 70), "a_" + strings.Repeat("b", 61)},
+		{Postgres, strings.Repeat("b", 62) + " c", strings.Repeat("b", 62)},
+		{Oracle, strings.Repeat("x", 29) + "_y", strings.Repeat("X", 29)},
+		{Oracle12, strings.Repeat("x", 29) + "_y", strings.Repeat("X", 29) + "_Y"},
+	}
+
+	for _, test := range tests {
+		if got := test.Dialect.SanitizeName(test.Name); got != test.Expected {
+			t.Errorf("%s: SanitizeName(%q) mismatch. Expected %q, got %q", test.Dialect.Name, test.Name, test.Expected, got)
+		}
+	}
+
+	users := []struct {
+		Name     string
+		Expected string
+	}{
+		{"Incredible Plastic", "incredible_plast"},
+		{"Sleek Concrete Ab", "sleek_concrete_a"},
+		{"Rustic Concrete x", "rustic_concrete"},
+		{"Fantastic_ Chair", "fantastic_chair"},
+	}
+
+	for _, test := range users {
+		if got := MySQL.SanitizeUserName(test.Name); got != test.Expected {
+			t.Errorf("SanitizeUserName(%q) mismatch. Expected %q, got %q", test.Name, test.Expected, got)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package sutils
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding identifies the character encoding of an input.
+type Encoding int
+
+// The encodings that DetectEncoding can recognise.
+const (
+	// UTF8 is UTF-8 (or plain ASCII) without a byte order mark.
+	UTF8 Encoding = iota
+	// UTF8BOM is UTF-8 prefixed with a byte order mark.
+	UTF8BOM
+	// UTF16LE is little-endian UTF-16, as written by most Windows tools.
+	UTF16LE
+	// UTF16BE is big-endian UTF-16.
+	UTF16BE
+	// Latin1 is ISO-8859-1. It is assumed for input that is not valid UTF-8
+	// and has no multi-byte UTF-8 characters either.
+	Latin1
+)
+
+// encodingSample is the number of bytes DetectEncoding looks at.
+const encodingSample = 4096
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case UTF8:
+		return "UTF-8"
+	case UTF8BOM:
+		return "UTF-8 (BOM)"
+	case UTF16LE:
+		return "UTF-16LE"
+	case UTF16BE:
+		return "UTF-16BE"
+	case Latin1:
+		return "ISO-8859-1"
+	}
+
+	return "unknown"
+}
+
+// BOM returns the byte order mark that introduces the encoding, or nil if
+// the encoding is not written with one.
+func (e Encoding) BOM() []byte {
+	switch e {
+	case UTF8BOM:
+		return bomUTF8
+	case UTF16LE:
+		return bomUTF16LE
+	case UTF16BE:
+		return bomUTF16BE
+	}
+
+	return nil
+}
+
+// DetectEncoding peeks at the beginning of r and guesses its encoding from
+// the byte order mark or, failing that, from the distribution of the bytes.
+//
+// The returned reader must be used in place of r, as it still holds the
+// bytes that were looked at. Errors from r are not reported here; they are
+// returned by the reader once the bytes before them have been read.
+func DetectEncoding(r io.Reader) (Encoding, io.Reader) {
+	br := bufio.NewReaderSize(r, encodingSample)
+	sample, _ := br.Peek(encodingSample)
+
+	return detect(sample, len(sample) == encodingSample), br
+}
+
+func detect(sample []byte, truncated bool) Encoding {
+	switch {
+	case bytes.HasPrefix(sample, bomUTF8):
+		return UTF8BOM
+	case bytes.HasPrefix(sample, bomUTF16LE):
+		return UTF16LE
+	case bytes.HasPrefix(sample, bomUTF16BE):
+		return UTF16BE
+	}
+
+	var even, odd int
+	for i := 0; i+1 < len(sample); i += 2 {
+		if sample[i] == 0 {
+			even++
+		}
+		if sample[i+1] == 0 {
+			odd++
+		}
+	}
+
+	// Mostly-ASCII UTF-16 has a zero in every other byte. Requiring the
+	// other half to be (nearly) free of zeroes keeps binary data out.
+	if pairs := len(sample) / 2; pairs > 0 {
+		switch {
+		case odd*10 > pairs*4 && even*10 < pairs:
+			return UTF16LE
+		case even*10 > pairs*4 && odd*10 < pairs:
+			return UTF16BE
+		}
+	}
+
+	if truncated {
+		sample = trimPartialRune(sample)
+	}
+
+	if utf8.Valid(sample) || hasMultiByteRune(sample) {
+		return UTF8
+	}
+
+	return Latin1
+}
+
+// hasMultiByteRune reports whether b holds a valid UTF-8 character of more
+// than one byte. Text that does is taken for UTF-8 with a few stray bytes,
+// rather than for Latin-1 that would turn its characters into mojibake.
+func hasMultiByteRune(b []byte) bool {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if size > 1 && r != utf8.RuneError {
+			return true
+		}
+
+		b = b[size:]
+	}
+
+	return false
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence from the end of b, which
+// happens when a sample is cut in the middle of a character.
+func trimPartialRune(b []byte) []byte {
+	for i := 1; i <= utf8.UTFMax && i <= len(b); i++ {
+		if utf8.RuneStart(b[len(b)-i]) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return b[:len(b)-i]
+			}
+
+			break
+		}
+	}
+
+	return b
+}
+
+// DecodeReader detects the encoding of r and returns a reader that yields its
+// contents transcoded to UTF-8, with any byte order mark removed.
+func DecodeReader(r io.Reader) (io.Reader, Encoding) {
+	enc, br := DetectEncoding(r)
+
+	return NewDecoder(br, enc), enc
+}
+
+// NewDecoder returns a reader that transcodes r from the given encoding to
+// UTF-8. A leading byte order mark, if present, is removed.
+func NewDecoder(r io.Reader, enc Encoding) io.Reader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	if bom := enc.BOM(); bom != nil {
+		if prefix, _ := br.Peek(len(bom)); bytes.Equal(prefix, bom) {
+			br.Discard(len(bom))
+		}
+	}
+
+	switch enc {
+	case UTF16LE:
+		return &utf16Reader{r: br, little: true}
+	case UTF16BE:
+		return &utf16Reader{r: br}
+	case Latin1:
+		return &latin1Reader{r: br}
+	}
+
+	return br
+}
+
+// utf16Reader transcodes UTF-16 to UTF-8.
+type utf16Reader struct {
+	r       *bufio.Reader
+	little  bool
+	out     []byte
+	pending rune
+	held    bool
+	err     error
+}
+
+func (u *utf16Reader) unit() (uint16, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(u.r, b[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			// A dangling odd byte cannot be decoded into anything.
+			return utf8.RuneError, nil
+		}
+
+		return 0, err
+	}
+
+	if u.little {
+		return uint16(b[0]) | uint16(b[1])<<8, nil
+	}
+
+	return uint16(b[0])<<8 | uint16(b[1]), nil
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.out) < len(p) && u.err == nil {
+		r := u.pending
+		if !u.held {
+			c, err := u.unit()
+			if err != nil {
+				u.err = err
+				break
+			}
+
+			r = rune(c)
+		}
+
+		u.held = false
+
+		if r >= 0xD800 && r < 0xDC00 {
+			next, err := u.unit()
+			if err != nil {
+				u.err = err
+			}
+
+			if dec := utf16.DecodeRune(r, rune(next)); dec != utf8.RuneError || err != nil {
+				r = dec
+			} else {
+				// Unpaired high surrogate: keep the following unit.
+				r, u.pending, u.held = utf8.RuneError, rune(next), true
+			}
+		} else if utf16.IsSurrogate(r) {
+			r = utf8.RuneError
+		}
+
+		u.out = appendRune(u.out, r)
+	}
+
+	n := copy(p, u.out)
+	u.out = u.out[n:]
+
+	if n == 0 && u.err != nil {
+		return 0, u.err
+	}
+
+	return n, nil
+}
+
+// latin1Reader transcodes ISO-8859-1 to UTF-8.
+type latin1Reader struct {
+	r   *bufio.Reader
+	out []byte
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	for len(l.out) < len(p) {
+		b, err := l.r.ReadByte()
+		if err != nil {
+			if len(l.out) == 0 {
+				return 0, err
+			}
+
+			break
+		}
+
+		l.out = appendRune(l.out, rune(b))
+	}
+
+	n := copy(p, l.out)
+	l.out = l.out[n:]
+
+	return n, nil
+}
+
+func appendRune(b []byte, r rune) []byte {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+
+	return append(b, buf[:n]...)
+}
+
+// NewEncodingWriter returns a writer that transcodes the UTF-8 written to it
+// into the given encoding before passing it on to w. It does not write a
+// byte order mark; callers that need one should write enc.BOM() first.
+func NewEncodingWriter(w io.Writer, enc Encoding) io.Writer {
+	switch enc {
+	case UTF16LE, UTF16BE, Latin1:
+		return &encodingWriter{w: w, enc: enc}
+	}
+
+	return w
+}
+
+type encodingWriter struct {
+	w       io.Writer
+	enc     Encoding
+	partial []byte
+}
+
+func (e *encodingWriter) Write(p []byte) (int, error) {
+	in := append(e.partial, p...)
+	out := make([]byte, 0, len(in)*2)
+
+	for len(in) > 0 {
+		if !utf8.FullRune(in) {
+			break
+		}
+
+		r, size := utf8.DecodeRune(in)
+		in = in[size:]
+
+		switch e.enc {
+		case Latin1:
+			if r > 0xFF {
+				r = '?'
+			}
+			out = append(out, byte(r))
+		default:
+			for _, c := range utf16.Encode([]rune{r}) {
+				if e.enc == UTF16LE {
+					out = append(out, byte(c), byte(c>>8))
+				} else {
+					out = append(out, byte(c>>8), byte(c))
+				}
+			}
+		}
+	}
+
+	e.partial = append([]byte(nil), in...)
+
+	if _, err := e.w.Write(out); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,148 @@
+package sutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+func utf16Bytes(s string, little, bom bool) []byte {
+	var b []byte
+
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+
+	for _, u := range units {
+		if little {
+			b = append(b, byte(u), byte(u>>8))
+		} else {
+			b = append(b, byte(u>>8), byte(u))
+		}
+	}
+
+	return b
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		Input    []byte
+		Expected Encoding
+	}{
+		{[]byte("plain ascii\n"), UTF8},
+		{[]byte("árvíztűrő tükörfúrógép\n"), UTF8},
+		{append([]byte{0xEF, 0xBB, 0xBF}, "with bom\n"...), UTF8BOM},
+		{utf16Bytes("windows log\r\n", true, true), UTF16LE},
+		{utf16Bytes("windows log\r\n", false, true), UTF16BE},
+		{utf16Bytes("no bom at all\r\n", true, false), UTF16LE},
+		{utf16Bytes("no bom at all\r\n", false, false), UTF16BE},
+		{[]byte("caf\xe9 au lait\n"), Latin1},
+		{[]byte("bad \xff byte\ncaf\u00e9 here\n"), UTF8},
+		{[]byte{}, UTF8},
+	}
+
+	for _, test := range tests {
+		enc, _ := DetectEncoding(bytes.NewReader(test.Input))
+		if enc != test.Expected {
+			t.Errorf("DetectEncoding(%q) mismatch. Expected %v, got %v", test.Input, test.Expected, enc)
+		}
+	}
+}
+
+func TestDecodeReader(t *testing.T) {
+	tests := []struct {
+		Input    []byte
+		Expected string
+	}{
+		{[]byte("plain ascii\n"), "plain ascii\n"},
+		{append([]byte{0xEF, 0xBB, 0xBF}, "with bom\n"...), "with bom\n"},
+		{utf16Bytes("Grüße 😀\r\n", true, true), "Grüße 😀\r\n"},
+		{utf16Bytes("Grüße 😀\r\n", false, true), "Grüße 😀\r\n"},
+		{[]byte("caf\xe9\n"), "café\n"},
+	}
+
+	for _, test := range tests {
+		r, _ := DecodeReader(bytes.NewReader(test.Input))
+		read, _ := ioutil.ReadAll(r)
+		if msg, ok := expect(test.Expected, string(read)); !ok {
+			t.Error(msg)
+		}
+	}
+}
+
+func TestFindInUTF16(t *testing.T) {
+	input := utf16Bytes("first line\r\nsecond ERROR line\r\nthird\r\nanother error\r\n", true, true)
+
+	found, err := FindCaseSensitive(bytes.NewReader(input), "ERROR")
+	if err != nil {
+		t.Fatalf("FindCaseSensitive errored out: %v", err)
+	}
+
+	if !reflect.DeepEqual([]int{2}, found) {
+		t.Errorf("FindCaseSensitive result mismatch. Expected %v, got %v", []int{2}, found)
+	}
+
+	found, err = FindWith(IContains, bytes.NewReader(input), []string{"error"})
+	if err != nil {
+		t.Fatalf("FindWith errored out: %v", err)
+	}
+
+	if !reflect.DeepEqual([]int{2, 4}, found) {
+		t.Errorf("FindWith result mismatch. Expected %v, got %v", []int{2, 4}, found)
+	}
+}
+
+func TestFindInUTF8WithStrayByte(t *testing.T) {
+	found, err := FindCaseSensitive(bytes.NewReader([]byte("bad \xff byte\ncafé here\n")), "café")
+	if err != nil {
+		t.Fatalf("FindCaseSensitive errored out: %v", err)
+	}
+
+	if !reflect.DeepEqual([]int{2}, found) {
+		t.Errorf("FindCaseSensitive result mismatch. Expected %v, got %v", []int{2}, found)
+	}
+}
+
+func TestCopyLinesKeepEncoding(t *testing.T) {
+	tests := []struct {
+		Input    []byte
+		Lines    []int
+		Expected []byte
+	}{
+		{
+			utf16Bytes("LineOne\r\nLineTwo\r\nLineThree\r\n", true, true),
+			[]int{2},
+			utf16Bytes("LineTwo\n", true, true),
+		},
+		{
+			utf16Bytes("LineOne\nLineTwo\n", false, true),
+			[]int{1, 2},
+			utf16Bytes("LineOne\nLineTwo\n", false, true),
+		},
+		{
+			[]byte("caf\xe9\nth\xe9\n"),
+			[]int{2},
+			[]byte("th\xe9\n"),
+		},
+		{
+			[]byte("LineOne\nLineTwo\n"),
+			[]int{1},
+			[]byte("LineOne\n"),
+		},
+	}
+
+	for _, test := range tests {
+		var to bytes.Buffer
+
+		if err := CopyLinesKeepEncoding(bytes.NewReader(test.Input), test.Lines, &to); err != nil {
+			t.Errorf("CopyLinesKeepEncoding(%q, %v, ..) errored out: %v", test.Input, test.Lines, err)
+		}
+
+		if !bytes.Equal(test.Expected, to.Bytes()) {
+			t.Errorf("CopyLinesKeepEncoding(%q, %v, ..) result mismatch. Expected %q, got %q", test.Input, test.Lines, test.Expected, to.Bytes())
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package sutils
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// EnvOption changes how an EnvSet treats a variable.
+type EnvOption int
+
+const (
+	// EnvRequired makes a variable required: Parse fails if it is not set
+	// or empty, instead of using its default.
+	EnvRequired EnvOption = 1 << iota
+
+	// EnvSecret keeps the value of a variable, like a password, out of the
+	// errors of Parse.
+	EnvSecret
+)
+
+// EnvSet loads typed values from environment variables, the way a
+// flag.FlagSet does from command line flags. Declare the variables, then
+// Parse them all at once:
+//
+//	env := NewEnvSet()
+//	host := env.String("DB_HOST", "", EnvRequired)
+//	port := env.Int("DB_PORT", 5432)
+//	pass := env.String("DB_PASSWORD", "", EnvRequired, EnvSecret)
+//	timeout := env.Duration("DB_TIMEOUT", 30*time.Second)
+//	if err := env.Parse(); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// Like Present, it treats empty variables as missing, so they get their
+// defaults.
+type EnvSet struct {
+	// Lookup looks up the variables. If nil, os.LookupEnv is used, so the
+	// zero EnvSet reads the environment of the process.
+	Lookup func(name string) (string, bool)
+
+	vars []*envVar
+}
+
+type envVar struct {
+	name     string
+	kind     string
+	required bool
+	secret   bool
+	set      func(value string) error
+}
+
+// NewEnvSet returns an EnvSet reading the environment of the process.
+func NewEnvSet() *EnvSet {
+	return &EnvSet{Lookup: os.LookupEnv}
+}
+
+func (e *EnvSet) add(name, kind string, opts []EnvOption, set func(string) error) {
+	v := &envVar{name: name, kind: kind, set: set}
+
+	for _, opt := range opts {
+		v.required = v.required || opt&EnvRequired != 0
+		v.secret = v.secret || opt&EnvSecret != 0
+	}
+
+	e.vars = append(e.vars, v)
+}
+
+// String declares a string variable with the default value, and returns a
+// pointer to where Parse stores its value.
+func (e *EnvSet) String(name, value string, opts ...EnvOption) *string {
+	p := new(string)
+	*p = value
+
+	e.add(name, "string", opts, func(s string) error {
+		*p = s
+		return nil
+	})
+
+	return p
+}
+
+// Int declares an integer variable with the default value, and returns a
+// pointer to where Parse stores its value.
+func (e *EnvSet) Int(name string, value int, opts ...EnvOption) *int {
+	p := new(int)
+	*p = value
+
+	e.add(name, "integer", opts, func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+
+		*p = n
+
+		return nil
+	})
+
+	return p
+}
+
+// Duration declares a variable holding a duration like "1m30s", see
+// time.ParseDuration, with the default value, and returns a pointer to where
+// Parse stores its value.
+func (e *EnvSet) Duration(name string, value time.Duration, opts ...EnvOption) *time.Duration {
+	p := new(time.Duration)
+	*p = value
+
+	e.add(name, "duration", opts, func(s string) error {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+
+		*p = d
+
+		return nil
+	})
+
+	return p
+}
+
+// Bool declares a boolean variable, one of the values strconv.ParseBool
+// accepts, with the default value, and returns a pointer to where Parse
+// stores its value.
+func (e *EnvSet) Bool(name string, value bool, opts ...EnvOption) *bool {
+	p := new(bool)
+	*p = value
+
+	e.add(name, "boolean", opts, func(s string) error {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+
+		*p = b
+
+		return nil
+	})
+
+	return p
+}
+
+// URL declares a variable holding an absolute URL, with the default value,
+// which may be "", and returns a pointer to where Parse stores its value.
+// It panics if the default is not a valid URL.
+func (e *EnvSet) URL(name, value string, opts ...EnvOption) *url.URL {
+	p := new(url.URL)
+
+	if value != "" {
+		u, err := url.Parse(value)
+		if err != nil {
+			panic(fmt.Sprintf("sutils: default of %s: %v", name, err))
+		}
+
+		*p = *u
+	}
+
+	e.add(name, "URL", opts, func(s string) error {
+		if err := URL.Check(s); err != nil {
+			return err
+		}
+
+		u, err := url.Parse(s)
+		if err != nil {
+			return err
+		}
+
+		*p = *u
+
+		return nil
+	})
+
+	return p
+}
+
+// Parse looks up the declared variables and stores their values, or the
+// defaults of those that are not set. It returns a *ValidationError listing
+// every required variable that is not set and every variable whose value is
+// not of its type, with the values of secret variables masked.
+func (e *EnvSet) Parse() error {
+	var v Validator
+
+	lookup := e.Lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+
+	for _, ev := range e.vars {
+		value, _ := lookup(ev.name)
+
+		if value == "" {
+			if ev.required {
+				v.Fail(ev.name, "required", "must be set")
+			}
+
+			continue
+		}
+
+		if err := ev.set(value); err != nil {
+			shown := strconv.Quote(value)
+			if ev.secret {
+				shown = "(secret)"
+			}
+
+			v.Fail(ev.name, ev.kind, fmt.Sprintf("must be a valid %s, got %s", ev.kind, shown))
+		}
+	}
+
+	return v.Err()
+}
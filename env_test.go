@@ -0,0 +1,106 @@
+package sutils
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEnv(vars map[string]string) *EnvSet {
+	env := NewEnvSet()
+	env.Lookup = func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	return env
+}
+
+func TestEnvSet(t *testing.T) {
+	env := testEnv(map[string]string{
+		"HOST":    "db.example.com",
+		"PORT":    "3306",
+		"TIMEOUT": "1m30s",
+		"DEBUG":   "true",
+		"API":     "https://api.example.com/v1",
+		"EMPTY":   "",
+	})
+
+	host := env.String("HOST", "localhost", EnvRequired)
+	port := env.Int("PORT", 5432)
+	timeout := env.Duration("TIMEOUT", time.Second)
+	debug := env.Bool("DEBUG", false)
+	api := env.URL("API", "")
+	empty := env.String("EMPTY", "default")
+	retries := env.Int("RETRIES", 3)
+	proxy := env.URL("PROXY", "http://proxy:8080")
+
+	if err := env.Parse(); err != nil {
+		t.Fatalf("Parse errored out: %v", err)
+	}
+
+	got := []interface{}{*host, *port, *timeout, *debug, api.Host, *empty, *retries, proxy.Host}
+	expected := []interface{}{"db.example.com", 3306, 90 * time.Second, true, "api.example.com", "default", 3, "proxy:8080"}
+
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("mismatch. Expected %v, got %v", expected, got)
+	}
+}
+
+func TestEnvSetErrors(t *testing.T) {
+	env := testEnv(map[string]string{
+		"PORT":     "http",
+		"PASSWORD": "hunter2",
+		"LIMIT":    "hunter3",
+		"API":      "api.example.com",
+		"EMPTY":    "",
+	})
+
+	env.Int("PORT", 5432)
+	env.String("PASSWORD", "", EnvRequired, EnvSecret)
+	env.Int("LIMIT", 0, EnvSecret)
+	env.URL("API", "")
+	env.String("EMPTY", "", EnvRequired)
+	env.String("USER", "", EnvRequired)
+
+	err := env.Parse()
+
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+
+	expected := []FieldError{
+		{Field: "PORT", Rule: "integer", Message: `must be a valid integer, got "http"`},
+		{Field: "LIMIT", Rule: "integer", Message: "must be a valid integer, got (secret)"},
+		{Field: "API", Rule: "URL", Message: `must be a valid URL, got "api.example.com"`},
+		{Field: "EMPTY", Rule: "required", Message: "must be set"},
+		{Field: "USER", Rule: "required", Message: "must be set"},
+	}
+
+	if !reflect.DeepEqual(expected, verr.Fields) {
+		t.Errorf("mismatch. Expected %v, got %v", expected, verr.Fields)
+	}
+
+	if strings.Contains(err.Error(), "hunter") {
+		t.Errorf("secret leaked in %q", err.Error())
+	}
+}
+
+func TestEnvSetZeroValue(t *testing.T) {
+	os.Setenv("SUTILS_TEST_ZERO", "42")
+	defer os.Unsetenv("SUTILS_TEST_ZERO")
+
+	var env EnvSet
+	n := env.Int("SUTILS_TEST_ZERO", 0)
+
+	if err := env.Parse(); err != nil {
+		t.Fatalf("Parse errored out: %v", err)
+	}
+
+	if *n != 42 {
+		t.Errorf("mismatch. Expected 42, got %d", *n)
+	}
+}
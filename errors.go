@@ -0,0 +1,40 @@
+package sutils
+
+import (
+	"fmt"
+)
+
+// Operations reported in a LineError.
+const (
+	OpRead  = "read"
+	OpWrite = "write"
+)
+
+// LineError is the error returned by the functions of the package when
+// reading their input or writing their output fails. It records where the
+// failure happened and wraps the underlying error, so errors.Is and
+// errors.As see through it.
+type LineError struct {
+	// Op is the operation that failed: OpRead or OpWrite.
+	Op string
+
+	// Line is the number of the line being read or written, counting from 1,
+	// or 0 if the failure happened before the line could be known.
+	Line int
+
+	// Offset is the byte offset of the failure in the input. For input that
+	// is not UTF-8, it is an offset into the input transcoded to UTF-8.
+	Offset int64
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("%s line %d (offset %d): %v", e.Op, e.Line, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
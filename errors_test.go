@@ -0,0 +1,155 @@
+package sutils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var errInjected = errors.New("injected failure")
+
+// failingReader returns its data and then fails instead of returning io.EOF.
+type failingReader struct {
+	data io.Reader
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.data.Read(p)
+	if err == io.EOF {
+		return n, errInjected
+	}
+
+	return n, err
+}
+
+// failingWriter accepts n writes and fails on every one after that.
+type failingWriter struct {
+	n int
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if f.n <= 0 {
+		return 0, errInjected
+	}
+
+	f.n--
+
+	return len(p), nil
+}
+
+func TestReadErrors(t *testing.T) {
+	const input = "line one\nline two\npartial"
+
+	funcs := map[string]func(io.Reader) error{
+		"FindIgnoreCase": func(r io.Reader) error {
+			_, err := FindIgnoreCase(r, "line")
+			return err
+		},
+		"FindCaseSensitive": func(r io.Reader) error {
+			_, err := FindCaseSensitive(r, "line")
+			return err
+		},
+		"FindStartsWith": func(r io.Reader) error {
+			_, err := FindStartsWith(r, "line")
+			return err
+		},
+		"FindWith": func(r io.Reader) error {
+			_, err := FindWith(strings.Contains, r, []string{"line"})
+			return err
+		},
+		"CountIgnoreCase": func(r io.Reader) error {
+			_, err := CountIgnoreCase(r, "line")
+			return err
+		},
+		"CountCaseSensitive": func(r io.Reader) error {
+			_, err := CountCaseSensitive(r, "line")
+			return err
+		},
+		"CopyLines": func(r io.Reader) error {
+			return CopyLines(r, []int{1}, ioutil.Discard)
+		},
+		"CopyLinesKeepEncoding": func(r io.Reader) error {
+			return CopyLinesKeepEncoding(r, []int{1}, ioutil.Discard)
+		},
+		"CopyWithoutLines": func(r io.Reader) error {
+			return CopyWithoutLines(r, []int{1}, ioutil.Discard)
+		},
+	}
+
+	for name, fn := range funcs {
+		err := fn(&failingReader{strings.NewReader(input)})
+		if !errors.Is(err, errInjected) {
+			t.Errorf("%s: expected the injected error, got %v", name, err)
+			continue
+		}
+
+		var lerr *LineError
+		if !errors.As(err, &lerr) {
+			t.Errorf("%s: expected a *LineError, got %T", name, err)
+			continue
+		}
+
+		if lerr.Op != OpRead || lerr.Line != 3 || lerr.Offset != int64(len(input)) {
+			t.Errorf("%s: expected read failure on line 3 at offset %d, got %+v", name, len(input), lerr)
+		}
+	}
+}
+
+func TestReadErrorBeforeAnyInput(t *testing.T) {
+	_, err := FindStartsWith(&failingReader{strings.NewReader("")}, "x")
+
+	var lerr *LineError
+	if !errors.As(err, &lerr) || !errors.Is(err, errInjected) {
+		t.Fatalf("expected a *LineError wrapping the injected error, got %v", err)
+	}
+
+	if lerr.Line != 1 || lerr.Offset != 0 {
+		t.Errorf("expected failure on line 1 at offset 0, got %+v", lerr)
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	const input = "line one\nline two\nline three\n"
+
+	tests := []struct {
+		Name string
+		Fn   func(io.Reader, io.Writer) error
+	}{
+		{"CopyLines", func(r io.Reader, w io.Writer) error { return CopyLines(r, []int{1, 2, 3}, w) }},
+		{"CopyLinesKeepEncoding", func(r io.Reader, w io.Writer) error { return CopyLinesKeepEncoding(r, []int{1, 2, 3}, w) }},
+		{"CopyWithoutLines", func(r io.Reader, w io.Writer) error { return CopyWithoutLines(r, nil, w) }},
+	}
+
+	for _, test := range tests {
+		err := test.Fn(strings.NewReader(input), &failingWriter{n: 1})
+
+		var lerr *LineError
+		if !errors.As(err, &lerr) || !errors.Is(err, errInjected) {
+			t.Errorf("%s: expected a *LineError wrapping the injected error, got %v", test.Name, err)
+			continue
+		}
+
+		if lerr.Op != OpWrite || lerr.Line != 2 || lerr.Offset != 9 {
+			t.Errorf("%s: expected write failure on line 2 at offset 9, got %+v", test.Name, lerr)
+		}
+	}
+}
+
+func TestLineTooLongError(t *testing.T) {
+	r := NewLineReader(bytes.NewBufferString("ok\n" + strings.Repeat("x", 10000) + "\n"))
+	r.MaxLineLength = 100
+
+	err := CopyWithoutLines(r, nil, ioutil.Discard)
+
+	var lerr *LineError
+	if !errors.As(err, &lerr) || !errors.Is(err, ErrLineTooLong) {
+		t.Fatalf("expected a *LineError wrapping ErrLineTooLong, got %v", err)
+	}
+
+	if lerr.Line != 2 || lerr.Offset != 3 {
+		t.Errorf("expected failure on line 2 at offset 3, got %+v", lerr)
+	}
+}
@@ -0,0 +1,350 @@
+package sutils
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultSortMemory is how many bytes of lines SortLines holds in memory
+// before it spills them to a temporary file.
+const DefaultSortMemory = 64 << 20
+
+// sortFanIn is how many runs SortLines merges at once. More runs are merged
+// in several passes, to keep the number of open files down.
+const sortFanIn = 64
+
+// SortOptions control how SortLines orders lines. The zero value sorts whole
+// lines by their bytes, like "LC_ALL=C sort" does.
+type SortOptions struct {
+	// Field sorts by the Field-th field of every line, counting from 1,
+	// instead of by the whole line. Lines without that field sort as if it
+	// was empty.
+	Field int
+
+	// Separator separates the fields. The default is runs of whitespace.
+	Separator string
+
+	// Numeric compares the keys as numbers. Keys that are not numbers are
+	// taken to be 0.
+	Numeric bool
+
+	// IgnoreCase compares the keys in a case-insensitive way.
+	IgnoreCase bool
+
+	// Reverse sorts in descending order.
+	Reverse bool
+
+	// Stable keeps lines with equal keys in their input order. By default
+	// they are ordered by their whole text, like "sort" does without -s.
+	Stable bool
+
+	// Memory is how many bytes of lines are held in memory at most, not
+	// counting the overhead of holding them. Zero means DefaultSortMemory.
+	Memory int64
+
+	// TempDir is where the sorted runs are written. The default is
+	// os.TempDir().
+	TempDir string
+}
+
+// sortLine is a line with the key it is sorted by.
+type sortLine struct {
+	text string
+	key  string
+	num  float64
+}
+
+func (o *SortOptions) line(text string) sortLine {
+	l := sortLine{text: text, key: text}
+
+	if o.Field > 0 {
+		var fields []string
+		if o.Separator == "" {
+			fields = strings.Fields(text)
+		} else {
+			fields = strings.Split(text, o.Separator)
+		}
+
+		l.key = ""
+		if o.Field <= len(fields) {
+			l.key = fields[o.Field-1]
+		}
+	}
+
+	if o.IgnoreCase {
+		l.key = strings.ToLower(l.key)
+	}
+
+	if o.Numeric {
+		l.num, _ = strconv.ParseFloat(strings.TrimSpace(l.key), 64)
+	}
+
+	return l
+}
+
+// compare compares two lines by their keys, and by their text if their keys
+// are equal and the sort is not stable.
+func (o *SortOptions) compare(a, b sortLine) int {
+	var cmp int
+
+	switch {
+	case o.Numeric && a.num < b.num:
+		cmp = -1
+	case o.Numeric && a.num > b.num:
+		cmp = 1
+	case !o.Numeric:
+		cmp = strings.Compare(a.key, b.key)
+	}
+
+	if cmp == 0 && !o.Stable {
+		cmp = strings.Compare(a.text, b.text)
+	}
+
+	if o.Reverse {
+		cmp = -cmp
+	}
+
+	return cmp
+}
+
+// SortLines sorts the lines of "from" and writes them to "to", ending every
+// line with "\n". Input that does not fit in opts.Memory is sorted in runs
+// that are written to temporary files, which are then merged. The temporary
+// files are removed before SortLines returns.
+func SortLines(from io.Reader, to io.Writer, opts SortOptions) error {
+	limit := opts.Memory
+	if limit <= 0 {
+		limit = DefaultSortMemory
+	}
+
+	var runs []string
+	defer func() {
+		for _, run := range runs {
+			os.Remove(run)
+		}
+	}()
+
+	r := lineReader(from)
+
+	var (
+		lines []sortLine
+		size  int64
+	)
+
+	for r.Scan() {
+		lines = append(lines, opts.line(r.Text()))
+		size += int64(len(r.Bytes()))
+
+		if size < limit {
+			continue
+		}
+
+		run, err := opts.writeRun(lines)
+		if err != nil {
+			return err
+		}
+
+		runs = append(runs, run)
+		lines, size = nil, 0
+	}
+
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	if len(runs) == 0 {
+		opts.sort(lines)
+		return writeSorted(to, lines)
+	}
+
+	if len(lines) > 0 {
+		run, err := opts.writeRun(lines)
+		if err != nil {
+			return err
+		}
+
+		runs = append(runs, run)
+	}
+
+	// Merge runs into bigger ones until they can all be merged at once. The
+	// merged run takes the place of the runs it was merged from, so the
+	// runs stay in input order.
+	for len(runs) > sortFanIn {
+		merged, err := opts.mergeToRun(runs[:sortFanIn])
+		if err != nil {
+			return err
+		}
+
+		for _, run := range runs[:sortFanIn] {
+			os.Remove(run)
+		}
+
+		runs = append([]string{merged}, runs[sortFanIn:]...)
+	}
+
+	return opts.merge(runs, to)
+}
+
+func (o *SortOptions) sort(lines []sortLine) {
+	sort.SliceStable(lines, func(i, j int) bool {
+		return o.compare(lines[i], lines[j]) < 0
+	})
+}
+
+func writeSorted(to io.Writer, lines []sortLine) error {
+	w := bufio.NewWriter(to)
+
+	for i, l := range lines {
+		if _, err := w.WriteString(l.text + "\n"); err != nil {
+			return &LineError{Op: OpWrite, Line: i + 1, Err: err}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return &LineError{Op: OpWrite, Line: len(lines), Err: err}
+	}
+
+	return nil
+}
+
+// writeRun sorts lines and writes them to a new temporary file, whose name
+// it returns.
+func (o *SortOptions) writeRun(lines []sortLine) (string, error) {
+	o.sort(lines)
+
+	f, err := ioutil.TempFile(o.TempDir, "sutils-sort-")
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeSorted(f, lines); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// mergeToRun merges runs into a new temporary file, whose name it returns.
+func (o *SortOptions) mergeToRun(runs []string) (string, error) {
+	f, err := ioutil.TempFile(o.TempDir, "sutils-sort-")
+	if err != nil {
+		return "", err
+	}
+
+	if err := o.merge(runs, f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// merge merges the sorted runs into "to". Lines with equal keys are taken
+// from the earlier run first, which keeps the sort stable.
+func (o *SortOptions) merge(runs []string, to io.Writer) error {
+	h := &runHeap{opts: o}
+
+	for i, run := range runs {
+		f, err := os.Open(run)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r := NewLineReader(f)
+		if r.Scan() {
+			h.runs = append(h.runs, runReader{r: r, index: i, line: o.line(r.Text())})
+		} else if err := r.Err(); err != nil {
+			return err
+		}
+	}
+
+	heap.Init(h)
+
+	w := bufio.NewWriter(to)
+	written := 0
+
+	for h.Len() > 0 {
+		top := &h.runs[0]
+
+		written++
+		if _, err := w.WriteString(top.line.text + "\n"); err != nil {
+			return &LineError{Op: OpWrite, Line: written, Err: err}
+		}
+
+		if top.r.Scan() {
+			top.line = o.line(top.r.Text())
+			heap.Fix(h, 0)
+
+			continue
+		}
+
+		if err := top.r.Err(); err != nil {
+			return err
+		}
+
+		heap.Pop(h)
+	}
+
+	if err := w.Flush(); err != nil {
+		return &LineError{Op: OpWrite, Line: written, Err: err}
+	}
+
+	return nil
+}
+
+// runReader is a sorted run being merged, and its current line.
+type runReader struct {
+	r     *LineReader
+	index int
+	line  sortLine
+}
+
+// runHeap is a min-heap of the runs being merged, by their current line.
+type runHeap struct {
+	opts *SortOptions
+	runs []runReader
+}
+
+func (h *runHeap) Len() int      { return len(h.runs) }
+func (h *runHeap) Swap(i, j int) { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+
+func (h *runHeap) Less(i, j int) bool {
+	if cmp := h.opts.compare(h.runs[i].line, h.runs[j].line); cmp != 0 {
+		return cmp < 0
+	}
+
+	return h.runs[i].index < h.runs[j].index
+}
+
+func (h *runHeap) Push(x interface{}) {
+	h.runs = append(h.runs, x.(runReader))
+}
+
+func (h *runHeap) Pop() interface{} {
+	run := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+
+	return run
+}
@@ -0,0 +1,95 @@
+package sutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSortLines(t *testing.T) {
+	input := "b 10\nA 9\na 100\nc 9\nB 2\n"
+
+	tests := []struct {
+		Name     string
+		Opts     SortOptions
+		Expected string
+	}{
+		{"bytes", SortOptions{}, "A 9\nB 2\na 100\nb 10\nc 9\n"},
+		{"reverse", SortOptions{Reverse: true}, "c 9\nb 10\na 100\nB 2\nA 9\n"},
+		{"ignore case", SortOptions{IgnoreCase: true}, "a 100\nA 9\nb 10\nB 2\nc 9\n"},
+		{"ignore case stable", SortOptions{IgnoreCase: true, Field: 1, Stable: true}, "A 9\na 100\nb 10\nB 2\nc 9\n"},
+		{"field", SortOptions{Field: 2}, "b 10\na 100\nB 2\nA 9\nc 9\n"},
+		{"numeric field", SortOptions{Field: 2, Numeric: true}, "B 2\nA 9\nc 9\nb 10\na 100\n"},
+		{"numeric field stable", SortOptions{Field: 2, Numeric: true, Stable: true, Reverse: true}, "a 100\nb 10\nA 9\nc 9\nB 2\n"},
+		{"separator", SortOptions{Field: 2, Separator: " ", Numeric: true, Reverse: true}, "a 100\nb 10\nc 9\nA 9\nB 2\n"},
+		{"missing field", SortOptions{Field: 3}, "A 9\nB 2\na 100\nb 10\nc 9\n"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := SortLines(strings.NewReader(input), &buf, test.Opts); err != nil {
+			t.Errorf("%s: SortLines errored out: %v", test.Name, err)
+			continue
+		}
+
+		if buf.String() != test.Expected {
+			t.Errorf("%s: SortLines mismatch. Expected %q, got %q", test.Name, test.Expected, buf.String())
+		}
+	}
+}
+
+func TestSortLinesExternal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sutils")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := randomLines(7, 20000)
+
+	expected := strings.Split(strings.TrimSuffix(input, "\n"), "\n")
+	sort.Strings(expected)
+
+	// About 300 runs, which takes more than one merge pass.
+	var buf bytes.Buffer
+	if err := SortLines(strings.NewReader(input), &buf, SortOptions{Memory: int64(len(input) / 300), TempDir: dir}); err != nil {
+		t.Fatalf("SortLines errored out: %v", err)
+	}
+
+	if got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"); !sort.StringsAreSorted(got) || len(got) != len(expected) {
+		t.Errorf("SortLines did not sort the %d lines, got %d lines", len(expected), len(got))
+	} else if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("SortLines lost or changed lines")
+	}
+
+	left, err := ioutil.ReadDir(dir)
+	if err != nil || len(left) != 0 {
+		t.Errorf("SortLines left %d temporary files behind (%v)", len(left), err)
+	}
+}
+
+func TestSortLinesExternalStable(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 3000; i++ {
+		sb.WriteString([]string{"b", "a", "c"}[i%3] + " " + strings.Repeat("x", i%7) + "\n")
+	}
+
+	opts := SortOptions{Field: 1, Stable: true}
+
+	var inMemory, external bytes.Buffer
+	if err := SortLines(strings.NewReader(sb.String()), &inMemory, opts); err != nil {
+		t.Fatalf("SortLines errored out: %v", err)
+	}
+
+	opts.Memory = 100
+	if err := SortLines(strings.NewReader(sb.String()), &external, opts); err != nil {
+		t.Fatalf("SortLines errored out: %v", err)
+	}
+
+	if inMemory.String() != external.String() {
+		t.Errorf("external stable sort differs from the in-memory one")
+	}
+}
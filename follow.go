@@ -0,0 +1,215 @@
+package sutils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultFollowInterval is how often Follow checks a file for changes.
+const DefaultFollowInterval = 250 * time.Millisecond
+
+// Match is a matching line found while following a file.
+type Match struct {
+	// Line is the number of the line, counting from 1. Numbering starts
+	// over when the file is truncated or replaced.
+	Line int
+
+	// Text is the line, without its line ending.
+	Text string
+}
+
+// Follower follows a file as it grows, the way "tail -f" does, reporting
+// the lines that match a set of needles. It notices when the file is
+// truncated, and when it is rotated, i.e. renamed or removed and a new file
+// is created in its place. The zero value is ready to use.
+type Follower struct {
+	// Interval is how often the file is checked for changes. Zero means
+	// DefaultFollowInterval.
+	Interval time.Duration
+
+	// FromStart makes the lines already in the file be searched as well.
+	// By default only lines appended after following starts are.
+	FromStart bool
+}
+
+// Follow follows the file at path with the default settings of a Follower.
+func Follow(ctx context.Context, path string, find func(string, string) bool, needles []string, fn func(Match) error) error {
+	var f Follower
+
+	return f.Follow(ctx, path, find, needles, fn)
+}
+
+// followStat is os.Stat, replaced by tests to change the file right before
+// Follow checks it.
+var followStat = os.Stat
+
+// followed is the state of the file being followed.
+type followed struct {
+	file    *os.File
+	info    os.FileInfo
+	offset  int64
+	line    int
+	pending []byte
+}
+
+// Follow follows the file at path until ctx is done, calling fn with every
+// line for which find returns true for one of the needles, like FindWith
+// would. A line is only reported once it is complete, i.e. once its newline
+// has been written, or when the file is rotated.
+//
+// Follow returns nil when ctx is done, and the error of fn if it returns one.
+func (f *Follower) Follow(ctx context.Context, path string, find func(string, string) bool, needles []string, fn func(Match) error) error {
+	interval := f.Interval
+	if interval <= 0 {
+		interval = DefaultFollowInterval
+	}
+
+	match := func(line string) bool {
+		for _, needle := range needles {
+			if needle != "" && find(line, needle) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	cur, err := openFollowed(path)
+	if err != nil {
+		return err
+	}
+	defer func() { cur.file.Close() }()
+
+	if !f.FromStart {
+		// Skip the existing lines, but count them to keep numbering right.
+		if err := cur.read(func(string) bool { return false }, nil); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := cur.read(match, fn); err != nil {
+			return err
+		}
+
+		info, err := followStat(path)
+		switch {
+		case err != nil:
+			// Rotated away and not yet recreated; the old file may still
+			// be written to, so keep following it.
+		case !os.SameFile(info, cur.info):
+			// Read what was written to the old file since it was last
+			// read. Its last line is finished now, even without a newline.
+			if err := cur.read(match, fn); err != nil {
+				return err
+			}
+
+			if err := cur.flush(match, fn); err != nil {
+				return err
+			}
+
+			next, err := openFollowed(path)
+			if err == nil {
+				cur.file.Close()
+				cur = next
+
+				continue
+			}
+		case info.Size() < cur.offset:
+			if _, err := cur.file.Seek(0, io.SeekStart); err != nil {
+				return &LineError{Op: OpRead, Line: cur.line + 1, Offset: cur.offset, Err: err}
+			}
+
+			cur.offset, cur.line, cur.pending = 0, 0, nil
+
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func openFollowed(path string) (*followed, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &followed{file: file, info: info}, nil
+}
+
+// read reads what has been appended to the file since the last call and
+// reports the complete lines among it that match.
+func (c *followed) read(match func(string) bool, fn func(Match) error) error {
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, err := c.file.Read(buf)
+		c.offset += int64(n)
+		c.pending = append(c.pending, buf[:n]...)
+
+		for {
+			i := bytes.IndexByte(c.pending, '\n')
+			if i < 0 {
+				break
+			}
+
+			line := c.pending[:i]
+			c.pending = c.pending[i+1:]
+
+			if err := c.emit(line, match, fn); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF {
+			// Keep the unfinished line, but not the memory behind the
+			// lines already reported.
+			c.pending = append([]byte(nil), c.pending...)
+			return nil
+		}
+
+		if err != nil {
+			return &LineError{Op: OpRead, Line: c.line + 1, Offset: c.offset, Err: err}
+		}
+	}
+}
+
+// flush reports the unfinished last line, if there is one.
+func (c *followed) flush(match func(string) bool, fn func(Match) error) error {
+	if len(c.pending) == 0 {
+		return nil
+	}
+
+	line := c.pending
+	c.pending = nil
+
+	return c.emit(line, match, fn)
+}
+
+func (c *followed) emit(line []byte, match func(string) bool, fn func(Match) error) error {
+	c.line++
+
+	text := string(bytes.TrimSuffix(line, []byte("\r")))
+	if !match(text) {
+		return nil
+	}
+
+	return fn(Match{Line: c.line, Text: text})
+}
@@ -0,0 +1,194 @@
+package sutils
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func appendTo(t *testing.T, path, content string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed opening %s: %v", path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("failed writing %s: %v", path, err)
+	}
+}
+
+// waitFor receives from matches until it has n of them or times out.
+func waitFor(t *testing.T, matches chan Match, n int) []Match {
+	var got []Match
+
+	timeout := time.After(5 * time.Second)
+	for len(got) < n {
+		select {
+		case m := <-matches:
+			got = append(got, m)
+		case <-timeout:
+			t.Fatalf("timed out waiting for %d matches, got %v", n, got)
+		}
+	}
+
+	return got
+}
+
+func TestFollow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sutils")
+	if err != nil {
+		t.Fatalf("failed creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	appendTo(t, path, "old ERROR that is skipped\nline two\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	matches := make(chan Match, 100)
+	done := make(chan error)
+
+	follower := Follower{Interval: 5 * time.Millisecond}
+	go func() {
+		done <- follower.Follow(ctx, path, strings.Contains, []string{"ERROR"}, func(m Match) error {
+			matches <- m
+			return nil
+		})
+	}()
+
+	// Give Follow time to skip the existing lines.
+	time.Sleep(50 * time.Millisecond)
+
+	appendTo(t, path, "new ERROR\nfine\nhalf an ERR")
+	time.Sleep(20 * time.Millisecond)
+	appendTo(t, path, "OR line\r\n")
+
+	got := waitFor(t, matches, 2)
+	expected := []Match{{3, "new ERROR"}, {5, "half an ERROR line"}}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("matches before rotation mismatch. Expected %v, got %v", expected, got)
+	}
+
+	// Rotate: the last line of the old file has no newline yet.
+	appendTo(t, path, "ERROR before rotation")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("failed rotating: %v", err)
+	}
+	appendTo(t, path, "ERROR in the new file\n")
+
+	got = waitFor(t, matches, 2)
+	expected = []Match{{6, "ERROR before rotation"}, {1, "ERROR in the new file"}}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("matches around rotation mismatch. Expected %v, got %v", expected, got)
+	}
+
+	// Truncate and start over.
+	time.Sleep(20 * time.Millisecond)
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatalf("failed truncating: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	appendTo(t, path, "ERROR\n")
+
+	got = waitFor(t, matches, 1)
+	expected = []Match{{1, "ERROR"}}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("matches after truncation mismatch. Expected %v, got %v", expected, got)
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Follow returned %v after cancellation", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Follow did not stop after cancellation")
+	}
+}
+
+func TestFollowCallbackError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sutils")
+	if err != nil {
+		t.Fatalf("failed creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	appendTo(t, path, "match\n")
+
+	stop := errors.New("stop")
+	follower := Follower{Interval: 5 * time.Millisecond, FromStart: true}
+
+	err = follower.Follow(context.Background(), path, strings.Contains, []string{"match"}, func(Match) error {
+		return stop
+	})
+	if err != stop {
+		t.Errorf("expected the callback's error, got %v", err)
+	}
+
+	if err := Follow(context.Background(), filepath.Join(dir, "missing"), strings.Contains, []string{"x"}, nil); err == nil {
+		t.Errorf("expected an error following a missing file")
+	}
+}
+
+func TestFollowWritesAfterRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sutils")
+	if err != nil {
+		t.Fatalf("failed creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	appendTo(t, path, "ERROR one\nERROR tw")
+
+	// Rotate right after the first read, then finish the half line and
+	// add another one to the old file before Follow checks the path.
+	rotated := false
+	followStat = func(name string) (os.FileInfo, error) {
+		if !rotated {
+			rotated = true
+
+			if err := os.Rename(path, path+".1"); err != nil {
+				t.Fatalf("failed rotating: %v", err)
+			}
+
+			appendTo(t, path+".1", "o\nERROR three\n")
+			appendTo(t, path, "ERROR new\n")
+		}
+
+		return os.Stat(name)
+	}
+	defer func() { followStat = os.Stat }()
+
+	var got []Match
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	follower := Follower{Interval: 5 * time.Millisecond, FromStart: true}
+	err = follower.Follow(ctx, path, strings.Contains, []string{"ERROR"}, func(m Match) error {
+		got = append(got, m)
+		if len(got) == 4 {
+			cancel()
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Follow errored out: %v", err)
+	}
+
+	expected := []Match{{1, "ERROR one"}, {2, "ERROR two"}, {3, "ERROR three"}, {1, "ERROR new"}}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("mismatch. Expected %v, got %v", expected, got)
+	}
+}
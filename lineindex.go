@@ -0,0 +1,363 @@
+package sutils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+)
+
+// lineIndexVersion is the version of the serialized LineIndex format.
+const lineIndexVersion = 2
+
+var lineIndexMagic = [4]byte{'S', 'L', 'I', 'X'}
+
+// ErrIndexStale is returned when a LineIndex is used with input that is
+// shorter than the input it was built from, or starts differently, which
+// means that it no longer describes it.
+var ErrIndexStale = errors.New("index is stale")
+
+// LineIndex records the byte offsets of every Nth line of an input, so that
+// a line can be reached by seeking close to it instead of reading every
+// line before it.
+//
+// Offsets are of the raw input, which has to be UTF-8 or another encoding
+// in which "\n" is a single byte.
+type LineIndex struct {
+	// Every is the distance, in lines, between two recorded offsets.
+	Every int
+
+	// Offsets[i] is the byte offset of line i*Every+1.
+	Offsets []int64
+
+	// Lines is the number of lines in the indexed input.
+	Lines int
+
+	// Size is the number of bytes of input indexed.
+	Size int64
+
+	// partial is true if the input did not end in a newline, so that the
+	// last line may continue when the input is appended to.
+	partial bool
+
+	// headSum is the checksum of the first headLen bytes of the input,
+	// which tells input that was appended to from input that was replaced.
+	headLen int64
+	headSum uint32
+}
+
+// headHash checksums the first headSize bytes written to it.
+type headHash struct {
+	h hash.Hash32
+	n int64
+}
+
+func (hh *headHash) Write(p []byte) (int, error) {
+	head := p
+	if rest := headSize - hh.n; int64(len(head)) > rest {
+		head = head[:rest]
+	}
+
+	hh.h.Write(head)
+	hh.n += int64(len(head))
+
+	return len(p), nil
+}
+
+// BuildLineIndex reads r to its end and records the offset of every
+// "every"th line.
+func BuildLineIndex(r io.Reader, every int) (*LineIndex, error) {
+	if every <= 0 {
+		return nil, errors.New("building line index: interval must be positive")
+	}
+
+	idx := &LineIndex{Every: every}
+	head := &headHash{h: crc32.NewIEEE()}
+
+	if err := idx.scan(io.TeeReader(r, head), 0, 0); err != nil {
+		return nil, err
+	}
+
+	idx.headLen, idx.headSum = head.n, head.h.Sum32()
+
+	return idx, nil
+}
+
+// scan indexes r, which starts at offset in the input, where line number
+// "line" is the last complete line before it.
+func (idx *LineIndex) scan(r io.Reader, offset int64, line int) error {
+	lr := NewLineReader(r)
+
+	for lr.Scan() {
+		if n := line + lr.Line(); (n-1)%idx.Every == 0 {
+			idx.Offsets = append(idx.Offsets, offset+lr.Offset())
+		}
+	}
+
+	if err := lr.Err(); err != nil {
+		var lerr *LineError
+		if errors.As(err, &lerr) {
+			lerr.Line += line
+			lerr.Offset += offset
+		}
+
+		return err
+	}
+
+	idx.Lines = line + lr.Line()
+	idx.Size = offset + lr.next
+	idx.partial = lr.Line() > 0 && !lr.terminated
+
+	return nil
+}
+
+// Extend indexes the bytes appended to the input since the index was built.
+// r must give access to the whole input, size being its current length.
+// It returns ErrIndexStale if the input has shrunk or its beginning has
+// changed, as when a file is rotated and grows past its old size, in which
+// case the index has to be rebuilt.
+func (idx *LineIndex) Extend(r io.ReaderAt, size int64) error {
+	switch {
+	case size < idx.Size:
+		return ErrIndexStale
+	case size == idx.Size:
+		return nil
+	}
+
+	sum, n, err := checksumHead(io.NewSectionReader(r, 0, size), idx.headLen)
+	if err != nil {
+		return &LineError{Op: OpRead, Offset: n, Err: err}
+	}
+
+	if n != idx.headLen || sum != idx.headSum {
+		return ErrIndexStale
+	}
+
+	offset, line := idx.Size, idx.Lines
+	if idx.partial {
+		// The last line was not finished; read it again from its start.
+		start, err := idx.seekLine(r, idx.Lines)
+		if err != nil {
+			return err
+		}
+
+		offset, line = start, idx.Lines-1
+		if (idx.Lines-1)%idx.Every == 0 {
+			idx.Offsets = idx.Offsets[:len(idx.Offsets)-1]
+		}
+	}
+
+	if err := idx.scan(io.NewSectionReader(r, offset, size-offset), offset, line); err != nil {
+		return err
+	}
+
+	if idx.headLen < headSize && idx.Size > idx.headLen {
+		// The head was shorter than it could be; cover what was appended.
+		head := idx.Size
+		if head > headSize {
+			head = headSize
+		}
+
+		sum, n, err := checksumHead(io.NewSectionReader(r, 0, head), head)
+		if err != nil {
+			return &LineError{Op: OpRead, Offset: n, Err: err}
+		}
+
+		idx.headLen, idx.headSum = n, sum
+	}
+
+	return nil
+}
+
+// Valid reports whether the index still covers an input of the given size
+// completely, i.e. whether the input has neither grown nor shrunk.
+func (idx *LineIndex) Valid(size int64) bool {
+	return size == idx.Size
+}
+
+// seekLine returns the byte offset at which the given line starts.
+func (idx *LineIndex) seekLine(r io.ReaderAt, line int) (int64, error) {
+	if line < 1 || line > idx.Lines {
+		return 0, fmt.Errorf("line %d out of range 1..%d", line, idx.Lines)
+	}
+
+	block := (line - 1) / idx.Every
+	offset := idx.Offsets[block]
+
+	lr := NewLineReader(io.NewSectionReader(r, offset, idx.Size-offset))
+	for skip := line - 1 - block*idx.Every; skip > 0; skip-- {
+		if !lr.Scan() {
+			break
+		}
+	}
+
+	if err := lr.Err(); err != nil {
+		return 0, err
+	}
+
+	return offset + lr.next, nil
+}
+
+// CopyRange copies lines "from" through "to", both inclusive and counting
+// from 1, from r to w, seeking to the first of them with the index. Like
+// CopyLines, it ends every line with "\n".
+func (idx *LineIndex) CopyRange(r io.ReaderAt, from, to int, w io.Writer) error {
+	if to > idx.Lines {
+		to = idx.Lines
+	}
+
+	if from < 1 {
+		from = 1
+	}
+
+	if from > to {
+		return nil
+	}
+
+	start, err := idx.seekLine(r, from)
+	if err != nil {
+		return err
+	}
+
+	lr := NewLineReader(io.NewSectionReader(r, start, idx.Size-start))
+	for lr.Scan() && lr.Line() <= to-from+1 {
+		if err := writeLine(w, lr); err != nil {
+			var lerr *LineError
+			if errors.As(err, &lerr) {
+				lerr.Line += from - 1
+				lerr.Offset += start
+			}
+
+			return err
+		}
+	}
+
+	return lr.Err()
+}
+
+// CopyLines works like the package level CopyLines, but uses the index to
+// seek to the runs of requested lines instead of reading the whole input.
+func (idx *LineIndex) CopyLines(r io.ReaderAt, lines []int, w io.Writer) error {
+	sorted := append([]int(nil), lines...)
+	sort.Ints(sorted)
+
+	for i := 0; i < len(sorted); {
+		// Copy runs of consecutive lines in one go.
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] <= sorted[j]+1 {
+			j++
+		}
+
+		if err := idx.CopyRange(r, sorted[i], sorted[j], w); err != nil {
+			return err
+		}
+
+		i = j + 1
+	}
+
+	return nil
+}
+
+// WriteTo writes the index to w in a versioned binary format that can be
+// read back with ReadLineIndex.
+func (idx *LineIndex) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	if _, err := cw.Write(lineIndexMagic[:]); err != nil {
+		return cw.n, err
+	}
+
+	var partial uint64
+	if idx.partial {
+		partial = 1
+	}
+
+	fields := []uint64{
+		lineIndexVersion, uint64(idx.Every), uint64(idx.Lines), uint64(idx.Size), partial,
+		uint64(idx.headLen), uint64(idx.headSum), uint64(len(idx.Offsets)),
+	}
+
+	// Offsets are increasing, so only the gaps are stored.
+	var prev int64
+	for _, o := range idx.Offsets {
+		fields = append(fields, uint64(o-prev))
+		prev = o
+	}
+
+	for _, v := range fields {
+		if err := cw.uvarint(v); err != nil {
+			return cw.n, err
+		}
+	}
+
+	return cw.n, cw.w.Flush()
+}
+
+// ReadLineIndex reads an index written by WriteTo. It returns
+// ErrIndexVersion if the index was written in another format version, and
+// an error if the index does not hold together, like one with fewer offsets
+// than it has lines to cover.
+func ReadLineIndex(r io.Reader) (*LineIndex, error) {
+	br := bufio.NewReader(r)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return nil, fmt.Errorf("reading line index: %v", err)
+	}
+
+	if magic != lineIndexMagic {
+		return nil, errors.New("reading line index: not a line index")
+	}
+
+	fields := make([]uint64, 8)
+	for i := range fields {
+		v, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading line index: %v", err)
+		}
+
+		if i == 0 && v != lineIndexVersion {
+			return nil, fmt.Errorf("reading line index: %w (%d)", ErrIndexVersion, v)
+		}
+
+		fields[i] = v
+	}
+
+	every, lines, size, headLen, offsets := fields[1], fields[2], fields[3], fields[5], fields[7]
+
+	switch {
+	case every == 0 || every > math.MaxInt32:
+		return nil, fmt.Errorf("reading line index: interval %d out of range", every)
+	case lines > math.MaxInt32 || size > math.MaxInt64 || headLen > size || headLen > headSize:
+		return nil, errors.New("reading line index: sizes out of range")
+	case offsets != (lines+every-1)/every:
+		return nil, fmt.Errorf("reading line index: %d offsets for %d lines every %d", offsets, lines, every)
+	}
+
+	idx := &LineIndex{
+		Every:   int(every),
+		Lines:   int(lines),
+		Size:    int64(size),
+		partial: fields[4] == 1,
+		headLen: int64(headLen),
+		headSum: uint32(fields[6]),
+	}
+
+	var prev int64
+	for i := uint64(0); i < offsets; i++ {
+		v, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading line index: %v", err)
+		}
+
+		prev += int64(v)
+		idx.Offsets = append(idx.Offsets, prev)
+	}
+
+	return idx, nil
+}
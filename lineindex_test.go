@@ -0,0 +1,178 @@
+package sutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func numberedLines(from, to int) string {
+	var b strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+
+	return b.String()
+}
+
+func TestLineIndexCopyRange(t *testing.T) {
+	input := numberedLines(1, 100)
+
+	for _, every := range []int{1, 3, 10, 1000} {
+		idx, err := BuildLineIndex(strings.NewReader(input), every)
+		if err != nil {
+			t.Fatalf("BuildLineIndex errored out: %v", err)
+		}
+
+		if idx.Lines != 100 || idx.Size != int64(len(input)) {
+			t.Errorf("BuildLineIndex(every=%d) counted %d lines and %d bytes", every, idx.Lines, idx.Size)
+		}
+
+		tests := []struct {
+			From, To int
+			Expected string
+		}{
+			{1, 1, "line 1\n"},
+			{1, 3, numberedLines(1, 3)},
+			{42, 57, numberedLines(42, 57)},
+			{99, 150, numberedLines(99, 100)},
+			{0, 2, numberedLines(1, 2)},
+			{50, 49, ""},
+		}
+
+		for _, test := range tests {
+			var out bytes.Buffer
+			if err := idx.CopyRange(strings.NewReader(input), test.From, test.To, &out); err != nil {
+				t.Errorf("CopyRange(%d, %d) errored out: %v", test.From, test.To, err)
+			}
+
+			if out.String() != test.Expected {
+				t.Errorf("CopyRange(%d, %d) with every=%d mismatch. Expected %q, got %q", test.From, test.To, every, test.Expected, out.String())
+			}
+		}
+	}
+}
+
+func TestLineIndexCopyLines(t *testing.T) {
+	input := "LineOne\r\nLineTwo\r\nLineThree"
+	lines := [][]int{{1, 2, 3}, {3, 1}, {2}, {}, {2, 2, 3}}
+
+	idx, err := BuildLineIndex(strings.NewReader(input), 2)
+	if err != nil {
+		t.Fatalf("BuildLineIndex errored out: %v", err)
+	}
+
+	for _, l := range lines {
+		var expected, got bytes.Buffer
+
+		CopyLines(strings.NewReader(input), l, &expected)
+
+		if err := idx.CopyLines(strings.NewReader(input), l, &got); err != nil {
+			t.Errorf("CopyLines(%v) errored out: %v", l, err)
+		}
+
+		if got.String() != expected.String() {
+			t.Errorf("CopyLines(%v) mismatch. Expected %q, got %q", l, expected.String(), got.String())
+		}
+	}
+}
+
+func TestLineIndexExtend(t *testing.T) {
+	tests := []struct {
+		Before, After string
+	}{
+		{numberedLines(1, 10), numberedLines(1, 25)},
+		{"first\nunfinis", "first\nunfinished line\nthird\n"},
+		{"", "now\nsomething\n"},
+		{numberedLines(1, 9) + "line 1", numberedLines(1, 9) + "line 10\n"},
+	}
+
+	for _, test := range tests {
+		idx, err := BuildLineIndex(strings.NewReader(test.Before), 3)
+		if err != nil {
+			t.Fatalf("BuildLineIndex errored out: %v", err)
+		}
+
+		if !idx.Valid(int64(len(test.Before))) || idx.Valid(int64(len(test.After))) {
+			t.Errorf("Valid should only hold for the indexed size")
+		}
+
+		if err := idx.Extend(strings.NewReader(test.After), int64(len(test.After))); err != nil {
+			t.Fatalf("Extend errored out: %v", err)
+		}
+
+		fresh, _ := BuildLineIndex(strings.NewReader(test.After), 3)
+		if !reflect.DeepEqual(fresh, idx) {
+			t.Errorf("Extend(%q -> %q) mismatch. Expected %+v, got %+v", test.Before, test.After, fresh, idx)
+		}
+	}
+
+	idx, _ := BuildLineIndex(strings.NewReader("long enough\n"), 1)
+	if err := idx.Extend(strings.NewReader("short"), 5); !errors.Is(err, ErrIndexStale) {
+		t.Errorf("expected ErrIndexStale for a truncated input, got %v", err)
+	}
+
+	// A rotated file that has grown past the old size starts differently.
+	idx, _ = BuildLineIndex(strings.NewReader(numberedLines(1, 10)), 3)
+	rotated := numberedLines(100, 120)
+	if err := idx.Extend(strings.NewReader(rotated), int64(len(rotated))); !errors.Is(err, ErrIndexStale) {
+		t.Errorf("expected ErrIndexStale for a replaced input, got %v", err)
+	}
+}
+
+func TestLineIndexSerialization(t *testing.T) {
+	idx, err := BuildLineIndex(strings.NewReader(numberedLines(1, 1000)+"partial"), 7)
+	if err != nil {
+		t.Fatalf("BuildLineIndex errored out: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := idx.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo errored out: %v", err)
+	}
+
+	read, err := ReadLineIndex(&buf)
+	if err != nil {
+		t.Fatalf("ReadLineIndex errored out: %v", err)
+	}
+
+	if !reflect.DeepEqual(idx, read) {
+		t.Errorf("index changed after writing and reading it back")
+	}
+
+	if _, err := ReadLineIndex(bytes.NewReader([]byte("SLIX\x01"))); !errors.Is(err, ErrIndexVersion) {
+		t.Errorf("expected ErrIndexVersion, got %v", err)
+	}
+}
+
+func TestReadLineIndexCorrupt(t *testing.T) {
+	index := func(fields ...uint64) []byte {
+		data := []byte("SLIX")
+		for _, v := range fields {
+			var buf [binary.MaxVarintLen64]byte
+			data = append(data, buf[:binary.PutUvarint(buf[:], v)]...)
+		}
+
+		return data
+	}
+
+	tests := []struct {
+		Name string
+		Data []byte
+	}{
+		{"no interval", index(2, 0, 10, 100, 0, 0, 0, 0)},
+		{"too few offsets", index(2, 3, 10, 100, 0, 0, 0, 3, 0, 30, 30)},
+		{"too many offsets", index(2, 3, 10, 100, 0, 0, 0, 5, 0, 30, 30, 30, 5)},
+		{"head past the end", index(2, 3, 1, 2, 0, 3, 0, 1, 0)},
+	}
+
+	for _, test := range tests {
+		if _, err := ReadLineIndex(bytes.NewReader(test.Data)); err == nil {
+			t.Errorf("%s: expected an error", test.Name)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package sutils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// LongLinePolicy decides what a LineReader does with a line that is longer
+// than its MaxLineLength.
+type LongLinePolicy int
+
+const (
+	// LongLineError stops reading and reports ErrLineTooLong.
+	LongLineError LongLinePolicy = iota
+	// LongLineTruncate returns the first MaxLineLength bytes of the line.
+	LongLineTruncate
+	// LongLineSkip leaves the line out. It is still counted, so the line
+	// numbers of the lines after it are unaffected.
+	LongLineSkip
+)
+
+// ErrLineTooLong is reported, wrapped in a LineError, when a line is longer
+// than the MaxLineLength of a LineReader using the LongLineError policy.
+var ErrLineTooLong = errors.New("line too long")
+
+// LineReader reads its input line by line, similarly to bufio.Scanner, but
+// without a limit on the length of a line: long lines are read in pieces
+// and stitched together. Lines end in "\n" or "\r\n", and a final line
+// without a newline is returned as well.
+//
+// Every function of the package that works on lines reads them through a
+// LineReader. Passing a *LineReader to them as the io.Reader makes them use
+// its settings instead of the defaults.
+type LineReader struct {
+	// MaxLineLength is the maximum length of a line in bytes, not counting
+	// the line ending. Zero means that there is no limit.
+	MaxLineLength int
+
+	// LongLines is what happens to lines longer than MaxLineLength.
+	LongLines LongLinePolicy
+
+	r          *bufio.Reader
+	line       []byte
+	num        int
+	offset     int64
+	next       int64
+	truncated  bool
+	terminated bool
+	err        error
+}
+
+// NewLineReader returns a LineReader reading from r with no line length limit.
+func NewLineReader(r io.Reader) *LineReader {
+	return &LineReader{r: bufio.NewReader(r)}
+}
+
+// lineReader returns r if it already is a LineReader, or a new LineReader
+// on r transcoded to UTF-8 otherwise.
+func lineReader(r io.Reader) *LineReader {
+	if lr, ok := r.(*LineReader); ok {
+		return lr
+	}
+
+	dec, _ := DecodeReader(r)
+
+	return NewLineReader(dec)
+}
+
+// Scan advances to the next line, which is then available through Bytes
+// or Text. It returns false at the end of the input or on an error, which
+// is then returned by Err.
+func (l *LineReader) Scan() bool {
+	for l.err == nil {
+		ok := l.readLine()
+		if !ok {
+			return false
+		}
+
+		if l.truncated && l.LongLines == LongLineSkip {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
+func (l *LineReader) readLine() bool {
+	l.line = l.line[:0]
+	l.truncated = false
+	l.offset = l.next
+
+	read := 0
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		read += len(chunk)
+		l.next += int64(len(chunk))
+		l.keep(chunk)
+
+		if err == bufio.ErrBufferFull {
+			// Two bytes are allowed for the line ending; anything beyond
+			// that is known to be too long without reading further.
+			if l.LongLines == LongLineError && l.MaxLineLength > 0 && read > l.MaxLineLength+2 {
+				l.fail(l.offset, ErrLineTooLong)
+				return false
+			}
+
+			continue
+		}
+
+		l.terminated = err == nil
+
+		if err == io.EOF && read == 0 {
+			l.err = io.EOF
+			return false
+		}
+
+		if err != nil && err != io.EOF {
+			l.fail(l.next, err)
+			return false
+		}
+
+		break
+	}
+
+	l.num++
+
+	l.line = bytes.TrimSuffix(l.line, []byte("\n"))
+	l.line = bytes.TrimSuffix(l.line, []byte("\r"))
+
+	if l.MaxLineLength > 0 && len(l.line) > l.MaxLineLength {
+		if l.LongLines == LongLineError {
+			l.num--
+			l.fail(l.offset, ErrLineTooLong)
+			return false
+		}
+
+		l.line = l.line[:l.MaxLineLength]
+		l.truncated = true
+	}
+
+	return true
+}
+
+// fail records err as having happened at offset while reading the next line.
+func (l *LineReader) fail(offset int64, err error) {
+	l.err = &LineError{Op: OpRead, Line: l.num + 1, Offset: offset, Err: err}
+}
+
+// keep appends chunk to the current line, dropping whatever does not fit
+// in MaxLineLength plus a two byte line ending.
+func (l *LineReader) keep(chunk []byte) {
+	if l.MaxLineLength > 0 {
+		if room := l.MaxLineLength + 2 - len(l.line); room < len(chunk) {
+			chunk = chunk[:room]
+		}
+	}
+
+	l.line = append(l.line, chunk...)
+}
+
+// Bytes returns the current line without its line ending. The slice is only
+// valid until the next call to Scan.
+func (l *LineReader) Bytes() []byte {
+	return l.line
+}
+
+// Text returns the current line without its line ending.
+func (l *LineReader) Text() string {
+	return string(l.line)
+}
+
+// Line returns the number of the current line, counting from 1.
+func (l *LineReader) Line() int {
+	return l.num
+}
+
+// Offset returns the byte offset at which the current line starts.
+func (l *LineReader) Offset() int64 {
+	return l.offset
+}
+
+// Truncated reports whether the current line was cut to MaxLineLength.
+func (l *LineReader) Truncated() bool {
+	return l.truncated
+}
+
+// Err returns the first error encountered by Scan as a *LineError, or nil
+// if the input was read to its end.
+func (l *LineReader) Err() error {
+	if l.err == io.EOF {
+		return nil
+	}
+
+	return l.err
+}
+
+// Read reads the input that has not yet been scanned, bypassing line
+// handling. It lets a LineReader be passed around as an io.Reader.
+func (l *LineReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.next += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,131 @@
+package sutils
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineReader(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Max      int
+		Policy   LongLinePolicy
+		Lines    []string
+		Numbers  []int
+		Offsets  []int64
+		TooLong  bool
+		Expected string
+	}{
+		{"one\ntwo\nthree\n", 0, LongLineError, []string{"one", "two", "three"}, []int{1, 2, 3}, []int64{0, 4, 8}, false, ""},
+		{"one\r\ntwo\r\nthree", 0, LongLineError, []string{"one", "two", "three"}, []int{1, 2, 3}, []int64{0, 5, 10}, false, ""},
+		{"\n\nx", 0, LongLineError, []string{"", "", "x"}, []int{1, 2, 3}, []int64{0, 1, 2}, false, ""},
+		{"", 0, LongLineError, nil, nil, nil, false, ""},
+
+		{"short\nmuch too long\nok\n", 5, LongLineTruncate, []string{"short", "much ", "ok"}, []int{1, 2, 3}, []int64{0, 6, 20}, false, ""},
+		{"short\nmuch too long\nok\n", 5, LongLineSkip, []string{"short", "ok"}, []int{1, 3}, []int64{0, 20}, false, ""},
+		{"short\nmuch too long\nok\n", 5, LongLineError, []string{"short"}, []int{1}, []int64{0}, true, ""},
+		{"exact\r\nfive!\n", 5, LongLineError, []string{"exact", "five!"}, []int{1, 2}, []int64{0, 7}, false, ""},
+	}
+
+	for _, test := range tests {
+		r := NewLineReader(strings.NewReader(test.Input))
+		r.MaxLineLength = test.Max
+		r.LongLines = test.Policy
+
+		var (
+			lines   []string
+			numbers []int
+			offsets []int64
+		)
+
+		for r.Scan() {
+			lines = append(lines, r.Text())
+			numbers = append(numbers, r.Line())
+			offsets = append(offsets, r.Offset())
+		}
+
+		if tooLong := errors.Is(r.Err(), ErrLineTooLong); tooLong != test.TooLong {
+			t.Errorf("LineReader(%q) error mismatch: %v", test.Input, r.Err())
+		}
+
+		if !reflect.DeepEqual(test.Lines, lines) {
+			t.Errorf("LineReader(%q) lines mismatch. Expected %q, got %q", test.Input, test.Lines, lines)
+		}
+
+		if !reflect.DeepEqual(test.Numbers, numbers) {
+			t.Errorf("LineReader(%q) line numbers mismatch. Expected %v, got %v", test.Input, test.Numbers, numbers)
+		}
+
+		if !reflect.DeepEqual(test.Offsets, offsets) {
+			t.Errorf("LineReader(%q) offsets mismatch. Expected %v, got %v", test.Input, test.Offsets, offsets)
+		}
+	}
+}
+
+func TestVeryLongLines(t *testing.T) {
+	long := strings.Repeat("{\"k\":\"v\"},", 1200*1024) // ~12 MB
+	input := "first\n" + long + "needle\nneedle at three\n"
+
+	found, err := FindWith(strings.Contains, strings.NewReader(input), []string{"needle"})
+	if err != nil {
+		t.Fatalf("FindWith errored out on a long line: %v", err)
+	}
+
+	if !reflect.DeepEqual([]int{2, 3}, found) {
+		t.Errorf("FindWith result mismatch. Expected %v, got %v", []int{2, 3}, found)
+	}
+
+	found, err = FindStartsWith(strings.NewReader(input), "needle")
+	if err != nil {
+		t.Fatalf("FindStartsWith errored out on a long line: %v", err)
+	}
+
+	if !reflect.DeepEqual([]int{3}, found) {
+		t.Errorf("FindStartsWith result mismatch. Expected %v, got %v", []int{3}, found)
+	}
+
+	var to bytes.Buffer
+	if err := CopyLines(strings.NewReader(input), []int{2}, &to); err != nil {
+		t.Fatalf("CopyLines errored out on a long line: %v", err)
+	}
+
+	if to.Len() != len(long)+len("needle\n") {
+		t.Errorf("CopyLines copied %d bytes, expected %d", to.Len(), len(long)+len("needle\n"))
+	}
+
+	to.Reset()
+	if err := CopyWithoutLines(strings.NewReader(input), []int{2}, &to); err != nil {
+		t.Fatalf("CopyWithoutLines errored out on a long line: %v", err)
+	}
+
+	if msg, ok := expect("first\nneedle at three\n", to.String()); !ok {
+		t.Error(msg)
+	}
+}
+
+func TestFindWithLineReaderPolicy(t *testing.T) {
+	input := "needle\n" + strings.Repeat("x", 100) + "needle\nneedle\n"
+
+	r := NewLineReader(strings.NewReader(input))
+	r.MaxLineLength = 50
+	r.LongLines = LongLineSkip
+
+	found, err := FindCaseSensitive(r, "needle")
+	if err != nil {
+		t.Fatalf("FindCaseSensitive errored out: %v", err)
+	}
+
+	if !reflect.DeepEqual([]int{1, 3}, found) {
+		t.Errorf("FindCaseSensitive result mismatch. Expected %v, got %v", []int{1, 3}, found)
+	}
+
+	r = NewLineReader(strings.NewReader(input))
+	r.MaxLineLength = 50
+
+	if _, err := FindCaseSensitive(r, "needle"); err == nil {
+		t.Errorf("FindCaseSensitive should have failed on a line over the limit")
+	}
+}
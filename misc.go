@@ -1,28 +1,19 @@
 package sutils
 
 import (
-	"strings"
-
 	"github.com/sethvargo/go-password/password"
-
-	"github.com/icrowley/fake"
 )
 
-// RandName returns a random username that can be used for databases
+// RandName returns a random username that can be used for databases. It is
+// at most 16 characters long, the MySQL limit; see Dialect for the others.
 func RandName() string {
-	tmp := strings.Split(fake.ProductName(), " ")[:2]
-	res := strings.ToLower(strings.Join(tmp, "_"))
-
-	if len(res) > 16 {
-		res = res[0:16]
-	}
-
-	return res
+	return MySQL.RandUserName()
 }
 
-// RandDBName returns a random name that can be used as a name for a database
+// RandDBName returns a random name that can be used as a name for a database,
+// of at most 64 characters, the MySQL limit.
 func RandDBName() string {
-	return RandName()
+	return MySQL.RandName()
 }
 
 // RandPassword returns a random password that can be used for databases
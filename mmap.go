@@ -0,0 +1,168 @@
+package sutils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+// ErrTruncated is the error of a search of a file mapped into memory when the
+// file gets truncated during the search, as log rotation by copytruncate
+// does.
+var ErrTruncated = errors.New("file truncated while being searched")
+
+// withMapping maps the unread part of f into memory and calls fn with it.
+// It returns false without calling fn if f cannot be searched that way: if
+// it is not a regular file, the platform does not support mapping files, or
+// the file is not UTF-8. Otherwise the file offset is moved to the end of
+// the file, as if it had been read.
+//
+// The mapping is only valid during fn. If the file is truncated while fn
+// reads the mapping, reading past the new end of the file faults; fn is
+// then stopped, and withMapping returns ErrTruncated in a *LineError.
+func withMapping(f *os.File, fn func(data []byte)) (bool, error) {
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return false, nil
+	}
+
+	size := info.Size()
+	if size == 0 || int64(int(size)) != size {
+		return false, nil
+	}
+
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil || offset >= size {
+		return false, nil
+	}
+
+	data, unmap, err := mmap(f, size)
+	if err != nil {
+		return false, nil
+	}
+	defer unmap()
+
+	data = data[offset:]
+
+	sample := data
+	if len(sample) > encodingSample {
+		sample = sample[:encodingSample]
+	}
+
+	switch detect(sample, len(sample) == encodingSample) {
+	case UTF8:
+	case UTF8BOM:
+		data = data[len(bomUTF8):]
+	default:
+		return false, nil
+	}
+
+	if err := callMapped(f, size, func() { fn(data) }); err != nil {
+		return true, &LineError{Op: OpRead, Err: err}
+	}
+
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		return true, &LineError{Op: OpRead, Offset: size, Err: err}
+	}
+
+	return true, nil
+}
+
+// callMapped calls fn, which reads the mapping of the first size bytes of f.
+// A fault reading the mapping, because f has been truncated, makes it return
+// ErrTruncated instead of crashing the process; other panics go on.
+func callMapped(f *os.File, size int64, fn func()) (err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		if _, ok := r.(runtime.Error); ok {
+			if info, statErr := f.Stat(); statErr == nil && info.Size() < size {
+				err = ErrTruncated
+				return
+			}
+		}
+
+		panic(r)
+	}()
+
+	fn()
+
+	return nil
+}
+
+// findMapped is findLines over a file mapped into memory. The boolean result
+// is false if the file could not be mapped.
+func findMapped(f *os.File, match func(string) bool) (occurrences []int, ok bool, err error) {
+	ok, err = withMapping(f, func(data []byte) {
+		for line := 1; len(data) > 0; line++ {
+			end := bytes.IndexByte(data, '\n')
+			if end < 0 {
+				end = len(data)
+			}
+
+			if match(string(bytes.TrimSuffix(data[:end], []byte("\r")))) {
+				occurrences = append(occurrences, line)
+			}
+
+			if end == len(data) {
+				break
+			}
+
+			data = data[end+1:]
+		}
+	})
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+
+	return occurrences, true, nil
+}
+
+// findMappedIndex returns the numbers of the lines in a file mapped into
+// memory that contain needle. Instead of looking at every line, it jumps
+// from match to match with bytes.Index and only counts the newlines in
+// between. The boolean result is false if the file could not be mapped or
+// needle cannot be searched for this way.
+func findMappedIndex(f *os.File, needle string) (occurrences []int, ok bool, err error) {
+	if needle == "" || bytes.ContainsAny([]byte(needle), "\r\n") {
+		return nil, false, nil
+	}
+
+	search := []byte(needle)
+
+	ok, err = withMapping(f, func(data []byte) {
+		// start is the offset of the beginning of line number "line".
+		line, start := 1, 0
+		for {
+			i := bytes.Index(data[start:], search)
+			if i < 0 {
+				break
+			}
+
+			at := start + i
+			line += bytes.Count(data[start:at], []byte("\n"))
+			occurrences = append(occurrences, line)
+
+			end := bytes.IndexByte(data[at:], '\n')
+			if end < 0 {
+				break
+			}
+
+			start = at + end + 1
+			line++
+		}
+	})
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+
+	return occurrences, true, nil
+}
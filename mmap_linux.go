@@ -0,0 +1,19 @@
+//go:build linux
+// +build linux
+
+package sutils
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmap maps the first size bytes of f into memory for reading.
+func mmap(f *os.File, size int64) ([]byte, func() error, error) {
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package sutils
+
+import (
+	"errors"
+	"os"
+)
+
+// mmap is not supported on this platform, so files are always streamed.
+func mmap(f *os.File, size int64) ([]byte, func() error, error) {
+	return nil, nil, errors.New("mmap not supported")
+}
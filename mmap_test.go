@@ -0,0 +1,147 @@
+package sutils
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func tempFileWith(t testing.TB, content string) *os.File {
+	f, err := ioutil.TempFile("", "sutils")
+	if err != nil {
+		t.Fatalf("failed creating temp file: %v", err)
+	}
+
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("failed writing temp file: %v", err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("failed rewinding temp file: %v", err)
+	}
+
+	return f
+}
+
+func TestFindInFile(t *testing.T) {
+	inputs := []string{
+		"",
+		"needle",
+		"needle\n",
+		"\xEF\xBB\xBFneedle first\nsecond\n",
+		"a\nneedle\nb\r\nNEEDLE\r\nneedle needle\nno",
+		"\n\n\nneedle\n\n",
+		randomLines(3, 2000),
+		randomLines(4, 2000) + "needle without newline",
+		string(utf16Bytes("first\r\nneedle\r\n", true, true)),
+	}
+
+	funcs := map[string]func(io.Reader) ([]int, error){
+		"FindCaseSensitive":        func(r io.Reader) ([]int, error) { return FindCaseSensitive(r, "needle") },
+		"FindCaseSensitive(empty)": func(r io.Reader) ([]int, error) { return FindCaseSensitive(r, "") },
+		"FindIgnoreCase":           func(r io.Reader) ([]int, error) { return FindIgnoreCase(r, "needle") },
+		"FindStartsWith":           func(r io.Reader) ([]int, error) { return FindStartsWith(r, "needle") },
+		"FindWith": func(r io.Reader) ([]int, error) {
+			return FindWith(strings.Contains, r, []string{"b", "needle"})
+		},
+	}
+
+	for _, input := range inputs {
+		f := tempFileWith(t, input)
+
+		for name, fn := range funcs {
+			expected, err := fn(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("%s on a string reader errored out: %v", name, err)
+			}
+
+			f.Seek(0, io.SeekStart)
+
+			found, err := fn(f)
+			if err != nil {
+				t.Errorf("%s on a file errored out: %v", name, err)
+			}
+
+			if !reflect.DeepEqual(expected, found) {
+				t.Errorf("%s(%.40q) on a file mismatch. Expected %v, got %v", name, input, expected, found)
+			}
+
+			if pos, _ := f.Seek(0, io.SeekCurrent); pos != int64(len(input)) {
+				t.Errorf("%s(%.40q) left the file at offset %d instead of the end", name, input, pos)
+			}
+		}
+
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+func TestFindInFileFromOffset(t *testing.T) {
+	f := tempFileWith(t, "needle\nskipped\nneedle\nlast\n")
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	f.Seek(7, io.SeekStart)
+
+	found, err := FindCaseSensitive(f, "needle")
+	if err != nil {
+		t.Fatalf("FindCaseSensitive errored out: %v", err)
+	}
+
+	if !reflect.DeepEqual([]int{2}, found) {
+		t.Errorf("FindCaseSensitive result mismatch. Expected %v, got %v", []int{2}, found)
+	}
+}
+
+func TestFindInTruncatedFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("files are only mapped into memory on Linux")
+	}
+
+	f := tempFileWith(t, strings.Repeat("some line of a log file\n", 100000))
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	truncated := false
+	truncate := func(line, needle string) bool {
+		if !truncated {
+			truncated = true
+			if err := os.Truncate(f.Name(), 0); err != nil {
+				t.Fatalf("failed truncating temp file: %v", err)
+			}
+		}
+
+		return strings.Contains(line, needle)
+	}
+
+	_, err := FindWith(truncate, f, []string{"log"})
+
+	var lerr *LineError
+	if !errors.As(err, &lerr) || !errors.Is(err, ErrTruncated) {
+		t.Errorf("expected a *LineError wrapping ErrTruncated, got %v", err)
+	}
+}
+
+/*
+============== Benchmarks ==============
+*/
+
+func BenchmarkFindCaseSensitiveFile(b *testing.B) {
+	largeHaystack()
+
+	f := tempFileWith(b, string(largeInput))
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		f.Seek(0, io.SeekStart)
+		FindCaseSensitive(f, "served in 996ms")
+	}
+}
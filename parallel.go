@@ -0,0 +1,261 @@
+package sutils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// minChunkSize is the smallest piece of input worth handing to a goroutine.
+const minChunkSize = 1 << 20
+
+// FindWithAt works like FindWith, but on input that can be read at arbitrary
+// offsets. The input is split into chunks at line boundaries, the chunks are
+// searched in parallel, and the results are merged so that the line numbers
+// are the same as FindWith would return.
+//
+// Unlike FindWith, FindWithAt does not detect the encoding of its input, which
+// must be UTF-8 or another encoding in which "\n" is a single byte.
+func FindWithAt(find func(string, string) bool, haystack io.ReaderAt, size int64, needles []string) ([]int, error) {
+	occurrences := make([]int, 0)
+
+	if needles[0] == "" {
+		return occurrences, nil
+	}
+
+	found, err := findLinesAt(haystack, size, chunkCount(size), func(line string) bool {
+		for _, needle := range needles {
+			if find(line, needle) {
+				return true
+			}
+		}
+
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(occurrences, found...), nil
+}
+
+// FindCaseSensitiveAt works like FindCaseSensitive, but searches the input
+// in parallel chunks as described at FindWithAt.
+func FindCaseSensitiveAt(haystack io.ReaderAt, size int64, needle string) ([]int, error) {
+	return findLinesAt(haystack, size, chunkCount(size), func(line string) bool {
+		return strings.Contains(line, needle)
+	})
+}
+
+// FindIgnoreCaseAt works like FindIgnoreCase, but searches the input in
+// parallel chunks as described at FindWithAt.
+func FindIgnoreCaseAt(haystack io.ReaderAt, size int64, needle string) ([]int, error) {
+	return findLinesAt(haystack, size, chunkCount(size), func(line string) bool {
+		return IContains(line, needle)
+	})
+}
+
+// CountLinesAt counts the lines in the first size bytes of r, counting the
+// newlines in parallel. A final line without a newline is counted as well.
+func CountLinesAt(r io.ReaderAt, size int64) (int, error) {
+	if size == 0 {
+		return 0, nil
+	}
+
+	n := chunkCount(size)
+	counts := make([]int, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			start, end := size*int64(i)/int64(n), size*int64(i+1)/int64(n)
+			counts[i], errs[i] = countNewlines(io.NewSectionReader(r, start, end-start))
+		}(i)
+	}
+	wg.Wait()
+
+	var lines int
+	for i := range counts {
+		if errs[i] != nil {
+			return 0, &LineError{Op: OpRead, Line: lines + 1, Err: errs[i]}
+		}
+
+		lines += counts[i]
+	}
+
+	last := make([]byte, 1)
+	if _, err := r.ReadAt(last, size-1); err != nil && err != io.EOF {
+		return 0, &LineError{Op: OpRead, Line: lines + 1, Offset: size - 1, Err: err}
+	}
+
+	if last[0] != '\n' {
+		lines++
+	}
+
+	return lines, nil
+}
+
+func countNewlines(r io.Reader) (int, error) {
+	var count int
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := r.Read(buf)
+		count += bytes.Count(buf[:n], []byte("\n"))
+
+		if err == io.EOF {
+			return count, nil
+		}
+
+		if err != nil {
+			return count, err
+		}
+	}
+}
+
+// chunkCount returns the number of pieces an input of the given size is
+// searched in.
+func chunkCount(size int64) int {
+	n := runtime.GOMAXPROCS(0)
+
+	if max := int((size + minChunkSize - 1) / minChunkSize); max < n {
+		n = max
+	}
+
+	if n < 1 {
+		n = 1
+	}
+
+	return n
+}
+
+// chunkResult is what searching one chunk yields: the matching line numbers
+// relative to the chunk, and the number of lines in it.
+type chunkResult struct {
+	found []int
+	lines int
+	err   error
+}
+
+func findLinesAt(haystack io.ReaderAt, size int64, chunks int, match func(string) bool) ([]int, error) {
+	bounds, err := chunkBounds(haystack, size, chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]chunkResult, len(bounds)-1)
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			start, end := bounds[i], bounds[i+1]
+			r := NewLineReader(io.NewSectionReader(haystack, start, end-start))
+
+			for r.Scan() {
+				if match(r.Text()) {
+					results[i].found = append(results[i].found, r.Line())
+				}
+			}
+
+			results[i].lines = r.Line()
+			results[i].err = r.Err()
+		}(i)
+	}
+	wg.Wait()
+
+	var (
+		occurrences []int
+		lines       int
+	)
+
+	for i, res := range results {
+		if res.err != nil {
+			var lerr *LineError
+			if errors.As(res.err, &lerr) {
+				lerr.Line += lines
+				lerr.Offset += bounds[i]
+			}
+
+			return nil, res.err
+		}
+
+		for _, l := range res.found {
+			occurrences = append(occurrences, lines+l)
+		}
+
+		lines += res.lines
+	}
+
+	return occurrences, nil
+}
+
+// chunkBounds splits the input into roughly equal chunks, moving every
+// boundary forward to the start of the next line. Chunks may end up empty.
+func chunkBounds(r io.ReaderAt, size int64, chunks int) ([]int64, error) {
+	bounds := make([]int64, chunks+1)
+	bounds[chunks] = size
+
+	for i := 1; i < chunks; i++ {
+		pos := size * int64(i) / int64(chunks)
+		if pos < bounds[i-1] {
+			pos = bounds[i-1]
+		}
+
+		start, err := nextLineStart(r, pos, size)
+		if err != nil {
+			return nil, err
+		}
+
+		bounds[i] = start
+	}
+
+	return bounds, nil
+}
+
+// nextLineStart returns the offset of the first line that starts at or
+// after pos, or size if there is none.
+func nextLineStart(r io.ReaderAt, pos, size int64) (int64, error) {
+	if pos == 0 {
+		return 0, nil
+	}
+
+	// Start from the byte before pos, so that a line starting exactly at
+	// pos is found.
+	pos--
+
+	buf := make([]byte, 64*1024)
+	for pos < size {
+		if rest := size - pos; rest < int64(len(buf)) {
+			buf = buf[:rest]
+		}
+
+		n, err := r.ReadAt(buf, pos)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return pos + int64(i) + 1, nil
+		}
+
+		if err != nil && err != io.EOF {
+			return 0, &LineError{Op: OpRead, Offset: pos + int64(n), Err: err}
+		}
+
+		if n == 0 {
+			break
+		}
+
+		pos += int64(n)
+	}
+
+	return size, nil
+}
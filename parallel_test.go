@@ -0,0 +1,156 @@
+package sutils
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func randomLines(seed int64, n int) string {
+	rng := rand.New(rand.NewSource(seed))
+	words := []string{"alpha", "beta", "gamma", "needle", "delta", "\r", ""}
+
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		for j := rng.Intn(8); j > 0; j-- {
+			b.WriteString(words[rng.Intn(len(words))])
+			b.WriteByte(' ')
+		}
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+func TestFindLinesAt(t *testing.T) {
+	inputs := []string{
+		"",
+		"\n",
+		"needle",
+		"needle\n",
+		"a\nneedle\nb\nneedle",
+		"\n\n\nneedle\n\n",
+		randomLines(1, 1000),
+		randomLines(2, 1000) + "needle without newline",
+	}
+
+	match := func(line string) bool { return strings.Contains(line, "needle") }
+
+	for _, input := range inputs {
+		expected, err := FindCaseSensitive(strings.NewReader(input), "needle")
+		if err != nil {
+			t.Fatalf("FindCaseSensitive errored out: %v", err)
+		}
+
+		for _, chunks := range []int{1, 2, 3, 7, 64} {
+			found, err := findLinesAt(strings.NewReader(input), int64(len(input)), chunks, match)
+			if err != nil {
+				t.Errorf("findLinesAt(%d chunks) errored out: %v", chunks, err)
+			}
+
+			if !reflect.DeepEqual(expected, found) {
+				t.Errorf("findLinesAt(%q, %d chunks) mismatch. Expected %v, got %v", input, chunks, expected, found)
+			}
+		}
+	}
+}
+
+func TestFindWithAt(t *testing.T) {
+	tests := []struct {
+		Haystack string
+		Needles  []string
+		Expected []int
+	}{
+		{"looking for this\nbut not for that\n", []string{""}, []int{}},
+		{"looking for this\nbut not for that\n", []string{"Madness"}, []int{}},
+		{"looking for this\nbut not for that\n", []string{"this"}, []int{1}},
+		{"looking for this\r\nbut not for that", []string{"that"}, []int{2}},
+		{"looking for this\nbut not for that\n", []string{"this", "that"}, []int{1, 2}},
+	}
+
+	for _, test := range tests {
+		r := strings.NewReader(test.Haystack)
+
+		found, err := FindWithAt(strings.Contains, r, r.Size(), test.Needles)
+		if err != nil {
+			t.Errorf("FindWithAt(strings.Contains, %q, %q) errored out: %v", test.Haystack, test.Needles, err)
+		}
+
+		if !reflect.DeepEqual(test.Expected, found) {
+			t.Errorf("FindWithAt(strings.Contains, %q, %q) result mismatch. Expected %#v, got %#v", test.Haystack, test.Needles, test.Expected, found)
+		}
+	}
+}
+
+func TestCountLinesAt(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Expected int
+	}{
+		{"", 0},
+		{"\n", 1},
+		{"one", 1},
+		{"one\ntwo", 2},
+		{"one\ntwo\n", 2},
+		{"one\r\ntwo\r\n\r\n", 3},
+		{strings.Repeat("line\n", 500000), 500000},
+	}
+
+	for _, test := range tests {
+		count, err := CountLinesAt(strings.NewReader(test.Input), int64(len(test.Input)))
+		if err != nil {
+			t.Errorf("CountLinesAt errored out: %v", err)
+		}
+
+		if count != test.Expected {
+			t.Errorf("CountLinesAt(%.20q) mismatch. Expected %d, got %d", test.Input, test.Expected, count)
+		}
+	}
+}
+
+/*
+============== Benchmarks ==============
+*/
+
+var (
+	largeInput     []byte
+	largeInputOnce sync.Once
+)
+
+// largeHaystack returns about 64 MB of log-like lines.
+func largeHaystack() *bytes.Reader {
+	largeInputOnce.Do(func() {
+		var b bytes.Buffer
+		for i := 0; b.Len() < 64<<20; i++ {
+			fmt.Fprintf(&b, "2017-06-01T12:00:%02d INFO request %d served in %dms\n", i%60, i, i%997)
+		}
+
+		largeInput = b.Bytes()
+	})
+
+	return bytes.NewReader(largeInput)
+}
+
+func BenchmarkFindCaseSensitiveLarge(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FindCaseSensitive(largeHaystack(), "served in 996ms")
+	}
+}
+
+func BenchmarkFindCaseSensitiveAtLarge(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		r := largeHaystack()
+		FindCaseSensitiveAt(r, r.Size(), "served in 996ms")
+	}
+}
+
+func BenchmarkCountLinesAtLarge(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		r := largeHaystack()
+		CountLinesAt(r, r.Size())
+	}
+}
@@ -0,0 +1,301 @@
+package sutils
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Line is a line passing through a LinePipeline.
+type Line struct {
+	// Number is the number of the line in the input, counting from 1.
+	Number int
+
+	// Text is the line, without its line ending.
+	Text string
+}
+
+// Stage is a step of a LinePipeline. It is called with every line that
+// reaches it, in order, and passes lines on to the next stage by calling
+// emit, any number of times. Returning ErrStopPipeline ends the pipeline
+// without an error; any other error ends it with that error.
+type Stage func(line Line, emit func(Line) error) error
+
+// ErrStopPipeline is returned by a Stage to end its pipeline early, without
+// reading the rest of the input.
+var ErrStopPipeline = errors.New("stop pipeline")
+
+// LinePipeline reads lines, passes them through a series of stages and
+// writes what comes out of the last one, in a single pass over the input:
+//
+//	err := Pipeline().Filter(MatchWith(strings.Contains, "ERROR")).Head(10).Number().Run(r, w)
+//
+// The methods adding stages return the pipeline, so they can be chained.
+// A pipeline can be run any number of times; every run starts its stages
+// afresh.
+type LinePipeline struct {
+	stages []func() Stage
+
+	concurrent bool
+	buffer     int
+}
+
+// Pipeline returns an empty pipeline, which copies its input as it is.
+func Pipeline() *LinePipeline {
+	return &LinePipeline{}
+}
+
+// MatchWith returns a match function for Filter and Exclude that reports
+// whether find returns true for the line and one of the needles, like
+// FindWith does.
+func MatchWith(find func(string, string) bool, needles ...string) func(string) bool {
+	return func(line string) bool {
+		for _, needle := range needles {
+			if needle != "" && find(line, needle) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Then adds a stage created by newStage for every run of the pipeline. Stages
+// that keep state between lines have to be created this way.
+func (p *LinePipeline) Then(newStage func() Stage) *LinePipeline {
+	p.stages = append(p.stages, newStage)
+	return p
+}
+
+// Use adds stage, which must not keep state between lines, to the pipeline.
+func (p *LinePipeline) Use(stage Stage) *LinePipeline {
+	return p.Then(func() Stage { return stage })
+}
+
+// Pipe adds the stages of another pipeline to the pipeline.
+func (p *LinePipeline) Pipe(other *LinePipeline) *LinePipeline {
+	p.stages = append(p.stages, other.stages...)
+	return p
+}
+
+// Filter passes on only the lines for which match returns true.
+func (p *LinePipeline) Filter(match func(string) bool) *LinePipeline {
+	return p.Use(func(line Line, emit func(Line) error) error {
+		if !match(line.Text) {
+			return nil
+		}
+
+		return emit(line)
+	})
+}
+
+// Exclude passes on only the lines for which match returns false.
+func (p *LinePipeline) Exclude(match func(string) bool) *LinePipeline {
+	return p.Filter(func(line string) bool { return !match(line) })
+}
+
+// Map replaces every line with what f returns for it.
+func (p *LinePipeline) Map(f func(string) string) *LinePipeline {
+	return p.Use(func(line Line, emit func(Line) error) error {
+		line.Text = f(line.Text)
+		return emit(line)
+	})
+}
+
+// MapLine is like Map, but f sees the number of the line as well.
+func (p *LinePipeline) MapLine(f func(Line) Line) *LinePipeline {
+	return p.Use(func(line Line, emit func(Line) error) error {
+		return emit(f(line))
+	})
+}
+
+// Head passes on the first n lines that reach it and ends the pipeline.
+func (p *LinePipeline) Head(n int) *LinePipeline {
+	return p.Then(func() Stage {
+		passed := 0
+
+		return func(line Line, emit func(Line) error) error {
+			if passed >= n {
+				return ErrStopPipeline
+			}
+
+			passed++
+			if err := emit(line); err != nil {
+				return err
+			}
+
+			if passed == n {
+				return ErrStopPipeline
+			}
+
+			return nil
+		}
+	})
+}
+
+// Skip drops the first n lines that reach it.
+func (p *LinePipeline) Skip(n int) *LinePipeline {
+	return p.Then(func() Stage {
+		skipped := 0
+
+		return func(line Line, emit func(Line) error) error {
+			if skipped < n {
+				skipped++
+				return nil
+			}
+
+			return emit(line)
+		}
+	})
+}
+
+// Number prefixes every line with its number in the input and a colon, the
+// way "grep -n" does.
+func (p *LinePipeline) Number() *LinePipeline {
+	return p.MapLine(func(line Line) Line {
+		line.Text = strconv.Itoa(line.Number) + ":" + line.Text
+		return line
+	})
+}
+
+// Concurrent makes the pipeline run every stage in a goroutine of its own,
+// connected to the next one by a channel holding up to buffer lines. A stage
+// that falls behind blocks the ones before it once the channel is full, so
+// memory use stays bounded. It pays off for stages that do a lot of work per
+// line.
+func (p *LinePipeline) Concurrent(buffer int) *LinePipeline {
+	if buffer < 1 {
+		buffer = 1
+	}
+
+	p.concurrent, p.buffer = true, buffer
+
+	return p
+}
+
+// Run reads the lines of "from", passes them through the stages and writes
+// the lines that come out at the end to "to", ending every line with "\n".
+// Like the Find functions, it transcodes "from" to UTF-8, unless it is a
+// *LineReader.
+func (p *LinePipeline) Run(from io.Reader, to io.Writer) error {
+	stages := make([]Stage, len(p.stages))
+	for i, newStage := range p.stages {
+		stages[i] = newStage()
+	}
+
+	r, w := lineReader(from), &lineWriter{w: to}
+
+	if p.concurrent {
+		return runConcurrent(r, w, stages, p.buffer)
+	}
+
+	emit := func(line Line) error { return w.write(line.Text) }
+	for i := len(stages) - 1; i >= 0; i-- {
+		stage, next := stages[i], emit
+		emit = func(line Line) error { return stage(line, next) }
+	}
+
+	for r.Scan() {
+		if err := emit(Line{r.Line(), r.Text()}); err != nil {
+			if err == ErrStopPipeline {
+				return nil
+			}
+
+			return err
+		}
+	}
+
+	return r.Err()
+}
+
+// runConcurrent runs the stages in goroutines of their own. Stage i reads
+// from lines[i] and writes to lines[i+1]; sending to lines[i] gives up once
+// stop[i] is closed, which stage i does when it ends early. That way the
+// stages before it end as well, one after the other.
+func runConcurrent(r *LineReader, w *lineWriter, stages []Stage, buffer int) error {
+	n := len(stages)
+
+	lines := make([]chan Line, n+1)
+	stop := make([]chan struct{}, n+1)
+	for i := range lines {
+		lines[i] = make(chan Line, buffer)
+		stop[i] = make(chan struct{})
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	fail := func(err error) {
+		if err != nil && err != ErrStopPipeline {
+			errOnce.Do(func() { firstErr = err })
+		}
+	}
+
+	send := func(i int, line Line) error {
+		select {
+		case lines[i] <- line:
+			return nil
+		case <-stop[i]:
+			return ErrStopPipeline
+		}
+	}
+
+	wg.Add(n + 1)
+
+	go func() {
+		defer wg.Done()
+		defer close(lines[0])
+
+		for r.Scan() {
+			if send(0, Line{r.Line(), r.Text()}) != nil {
+				return
+			}
+		}
+
+		fail(r.Err())
+	}()
+
+	for i, stage := range stages {
+		go func(i int, stage Stage) {
+			defer wg.Done()
+			defer close(lines[i+1])
+
+			emit := func(line Line) error { return send(i+1, line) }
+
+			for line := range lines[i] {
+				// A stage that drops lines may not notice that the stage
+				// after it has ended for a long time otherwise.
+				select {
+				case <-stop[i+1]:
+					close(stop[i])
+					return
+				default:
+				}
+
+				if err := stage(line, emit); err != nil {
+					fail(err)
+					close(stop[i])
+
+					return
+				}
+			}
+		}(i, stage)
+	}
+
+	for line := range lines[n] {
+		if err := w.write(line.Text); err != nil {
+			fail(err)
+			close(stop[n])
+
+			break
+		}
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
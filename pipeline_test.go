@@ -0,0 +1,126 @@
+package sutils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	input := "INFO start\nERROR disk full\nDEBUG x\nERROR net down\r\nINFO retry\nERROR disk full again\n"
+
+	tests := []struct {
+		Name     string
+		Pipeline *LinePipeline
+		Expected string
+	}{
+		{"copy", Pipeline(), "INFO start\nERROR disk full\nDEBUG x\nERROR net down\nINFO retry\nERROR disk full again\n"},
+		{"filter", Pipeline().Filter(MatchWith(strings.Contains, "ERROR")), "ERROR disk full\nERROR net down\nERROR disk full again\n"},
+		{"exclude", Pipeline().Exclude(MatchWith(strings.HasPrefix, "INFO", "DEBUG")), "ERROR disk full\nERROR net down\nERROR disk full again\n"},
+		{"map", Pipeline().Filter(MatchWith(IContains, "disk")).Map(strings.ToLower), "error disk full\nerror disk full again\n"},
+		{"head", Pipeline().Filter(MatchWith(strings.Contains, "ERROR")).Head(2).Number(), "2:ERROR disk full\n4:ERROR net down\n"},
+		{"skip", Pipeline().Skip(4).Number(), "5:INFO retry\n6:ERROR disk full again\n"},
+		{"pipe", Pipeline().Pipe(Pipeline().Filter(MatchWith(strings.Contains, "INFO"))).Head(1), "INFO start\n"},
+		{"custom", Pipeline().Use(func(line Line, emit func(Line) error) error {
+			for _, word := range strings.Fields(line.Text)[1:] {
+				if err := emit(Line{line.Number, word}); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}).Head(3), "start\ndisk\nfull\n"},
+	}
+
+	for _, test := range tests {
+		for _, concurrent := range []bool{false, true} {
+			p := test.Pipeline
+			if concurrent {
+				p = Pipeline().Pipe(p).Concurrent(2)
+			}
+
+			// Running a pipeline twice starts its stages afresh.
+			for run := 0; run < 2; run++ {
+				var buf bytes.Buffer
+				if err := p.Run(strings.NewReader(input), &buf); err != nil {
+					t.Errorf("%s (concurrent: %v): Run errored out: %v", test.Name, concurrent, err)
+					continue
+				}
+
+				if buf.String() != test.Expected {
+					t.Errorf("%s (concurrent: %v): mismatch. Expected %q, got %q", test.Name, concurrent, test.Expected, buf.String())
+				}
+			}
+		}
+	}
+}
+
+// endlessReader produces numbered lines forever.
+type endlessReader struct {
+	n   int
+	buf []byte
+}
+
+func (e *endlessReader) Read(p []byte) (int, error) {
+	if len(e.buf) == 0 {
+		e.n++
+		e.buf = []byte(fmt.Sprintf("line %d\n", e.n))
+	}
+
+	n := copy(p, e.buf)
+	e.buf = e.buf[n:]
+
+	return n, nil
+}
+
+func TestPipelineHeadStopsReading(t *testing.T) {
+	for _, concurrent := range []bool{false, true} {
+		p := Pipeline().Filter(MatchWith(strings.HasSuffix, "0")).Head(3)
+		if concurrent {
+			p.Concurrent(4)
+		}
+
+		var buf bytes.Buffer
+		if err := p.Run(&endlessReader{}, &buf); err != nil {
+			t.Fatalf("Run errored out: %v", err)
+		}
+
+		if expected := "line 10\nline 20\nline 30\n"; buf.String() != expected {
+			t.Errorf("concurrent: %v: expected %q, got %q", concurrent, expected, buf.String())
+		}
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	errStage := errors.New("stage failed")
+
+	for _, concurrent := range []bool{false, true} {
+		p := Pipeline().Use(func(line Line, emit func(Line) error) error {
+			if line.Number == 3 {
+				return errStage
+			}
+
+			return emit(line)
+		})
+		if concurrent {
+			p.Concurrent(1)
+		}
+
+		if err := p.Run(&endlessReader{}, ioutil.Discard); err != errStage {
+			t.Errorf("concurrent: %v: expected the stage's error, got %v", concurrent, err)
+		}
+
+		p = Pipeline().Filter(MatchWith(strings.Contains, "line"))
+		if concurrent {
+			p.Concurrent(1)
+		}
+
+		var lerr *LineError
+		if err := p.Run(&endlessReader{}, &failingWriter{n: 2}); !errors.Is(err, errInjected) || !errors.As(err, &lerr) || lerr.Line != 3 {
+			t.Errorf("concurrent: %v: expected the injected error writing line 3, got %v", concurrent, err)
+		}
+	}
+}
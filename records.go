@@ -0,0 +1,189 @@
+package sutils
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Record is a logical record of one or more consecutive lines, like a log
+// entry followed by the lines of a stack trace.
+type Record struct {
+	// Start and End are the numbers of the first and last line of the
+	// record, counting from 1.
+	Start, End int
+
+	// Lines are the lines of the record, without their line endings.
+	Lines []string
+}
+
+// Text returns the lines of the record joined by newlines.
+func (r Record) Text() string {
+	return strings.Join(r.Lines, "\n")
+}
+
+// RecordRule decides whether a line continues the record of the line before
+// it, prev, or starts a new record.
+type RecordRule func(prev, line string) bool
+
+// IndentContinuation continues a record with lines that start with a space
+// or a tab, like the "at" lines of a Java stack trace.
+func IndentContinuation(prev, line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
+
+// BackslashContinuation continues a record after lines that end in a
+// backslash.
+func BackslashContinuation(prev, line string) bool {
+	return strings.HasSuffix(prev, "\\")
+}
+
+// TimestampStart starts a new record with every line that starts with a
+// timestamp, in one of the layouts DetectTimestamp knows, and continues the
+// record with every other line.
+func TimestampStart(prev, line string) bool {
+	_, ok := leadingTimestamp(line)
+	return !ok
+}
+
+// StartPattern returns a rule that starts a new record with every line that
+// matches re, and continues the record with every other line.
+func StartPattern(re *regexp.Regexp) RecordRule {
+	return func(prev, line string) bool {
+		return !re.MatchString(line)
+	}
+}
+
+// AnyRule returns a rule that continues a record if any of rules does.
+func AnyRule(rules ...RecordRule) RecordRule {
+	return func(prev, line string) bool {
+		for _, rule := range rules {
+			if rule(prev, line) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// RecordReader groups the lines of its input into records.
+type RecordReader struct {
+	lr   *LineReader
+	rule RecordRule
+
+	cur    Record
+	next   Record
+	queued bool
+}
+
+// NewRecordReader returns a RecordReader reading from r, grouping lines by
+// rule. Like the Find functions, it transcodes r to UTF-8, unless r is a
+// *LineReader.
+func NewRecordReader(r io.Reader, rule RecordRule) *RecordReader {
+	return &RecordReader{lr: lineReader(r), rule: rule}
+}
+
+// Scan advances to the next record, which is then available through Record.
+// It returns false at the end of the input or on an error, which is then
+// returned by Err.
+func (r *RecordReader) Scan() bool {
+	for r.lr.Scan() {
+		line, num := r.lr.Text(), r.lr.Line()
+
+		if r.queued && r.rule(r.next.Lines[len(r.next.Lines)-1], line) {
+			r.next.Lines = append(r.next.Lines, line)
+			r.next.End = num
+
+			continue
+		}
+
+		started := Record{Start: num, End: num, Lines: []string{line}}
+		if !r.queued {
+			r.next, r.queued = started, true
+			continue
+		}
+
+		r.cur, r.next = r.next, started
+
+		return true
+	}
+
+	if r.queued && r.lr.Err() == nil {
+		r.cur, r.queued = r.next, false
+		return true
+	}
+
+	return false
+}
+
+// Record returns the current record.
+func (r *RecordReader) Record() Record {
+	return r.cur
+}
+
+// Err returns the first error encountered by Scan as a *LineError, or nil
+// if the input was read to its end.
+func (r *RecordReader) Err() error {
+	return r.lr.Err()
+}
+
+// FindRecordsWith returns the records of haystack, grouped by rule, whose
+// text contains one of the needles according to find, like FindWith does
+// for lines. The text of a record is its lines joined by newlines.
+func FindRecordsWith(find func(string, string) bool, haystack io.Reader, needles []string, rule RecordRule) ([]Record, error) {
+	records := make([]Record, 0)
+
+	if needles[0] == "" {
+		return records, nil
+	}
+
+	r := NewRecordReader(haystack, rule)
+	for r.Scan() {
+		rec := r.Record()
+		text := rec.Text()
+
+		for _, needle := range needles {
+			if find(text, needle) {
+				records = append(records, rec)
+				break
+			}
+		}
+	}
+
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// CountRecordsWith returns the number of records FindRecordsWith finds.
+func CountRecordsWith(find func(string, string) bool, haystack io.Reader, needles []string, rule RecordRule) (int, error) {
+	records, err := FindRecordsWith(find, haystack, needles, rule)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(records), nil
+}
+
+// RecordLines returns the numbers of all the lines of the records, which can
+// be passed on to CopyLines or CopyWithoutLines.
+func RecordLines(records []Record) []int {
+	var lines []int
+
+	for _, rec := range records {
+		for l := rec.Start; l <= rec.End; l++ {
+			lines = append(lines, l)
+		}
+	}
+
+	return lines
+}
+
+// CopyRecords copies every line of the records from the io.Reader "from" to
+// the io.Writer "to", like CopyLines does.
+func CopyRecords(from io.Reader, records []Record, to io.Writer) error {
+	return CopyLines(from, RecordLines(records), to)
+}
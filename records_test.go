@@ -0,0 +1,84 @@
+package sutils
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const javaLog = `2017-06-01 12:00:00 INFO starting
+2017-06-01 12:00:01 ERROR request failed
+java.lang.NullPointerException: boom
+	at com.example.Foo.bar(Foo.java:42)
+	at com.example.Main.main(Main.java:7)
+Caused by: java.lang.IllegalStateException
+	... 2 more
+2017-06-01 12:00:02 INFO done
+`
+
+func TestRecordReader(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Input string
+		Rule  RecordRule
+		Spans [][2]int
+	}{
+		{"timestamp", javaLog, TimestampStart, [][2]int{{1, 1}, {2, 7}, {8, 8}}},
+		{"indent", javaLog, IndentContinuation, [][2]int{{1, 1}, {2, 2}, {3, 5}, {6, 7}, {8, 8}}},
+		{"pattern", javaLog, StartPattern(regexp.MustCompile(`^\d{4}-`)), [][2]int{{1, 1}, {2, 7}, {8, 8}}},
+		{"backslash", "one \\\n  two\nthree\\\nfour\\\nfive\nsix", BackslashContinuation, [][2]int{{1, 2}, {3, 5}, {6, 6}}},
+		{"any", "a\n  b\nc \\\nd\ne", AnyRule(IndentContinuation, BackslashContinuation), [][2]int{{1, 2}, {3, 4}, {5, 5}}},
+		{"continuation first", "  indented\nnext", IndentContinuation, [][2]int{{1, 1}, {2, 2}}},
+		{"empty", "", TimestampStart, nil},
+	}
+
+	for _, test := range tests {
+		r := NewRecordReader(strings.NewReader(test.Input), test.Rule)
+
+		var spans [][2]int
+		for r.Scan() {
+			rec := r.Record()
+			if len(rec.Lines) != rec.End-rec.Start+1 {
+				t.Errorf("%s: record %d-%d has %d lines", test.Name, rec.Start, rec.End, len(rec.Lines))
+			}
+
+			spans = append(spans, [2]int{rec.Start, rec.End})
+		}
+
+		if err := r.Err(); err != nil {
+			t.Errorf("%s: RecordReader errored out: %v", test.Name, err)
+		}
+
+		if !reflect.DeepEqual(test.Spans, spans) {
+			t.Errorf("%s: record spans mismatch. Expected %v, got %v", test.Name, test.Spans, spans)
+		}
+	}
+}
+
+func TestFindRecordsWith(t *testing.T) {
+	records, err := FindRecordsWith(IContains, strings.NewReader(javaLog), []string{"nullpointerexception"}, TimestampStart)
+	if err != nil {
+		t.Fatalf("FindRecordsWith errored out: %v", err)
+	}
+
+	if len(records) != 1 || records[0].Start != 2 || records[0].End != 7 {
+		t.Fatalf("FindRecordsWith mismatch. Expected one record at 2-7, got %+v", records)
+	}
+
+	count, err := CountRecordsWith(strings.Contains, strings.NewReader(javaLog), []string{"INFO", "Caused by"}, TimestampStart)
+	if err != nil || count != 3 {
+		t.Errorf("CountRecordsWith mismatch. Expected 3, got %d (%v)", count, err)
+	}
+
+	var out bytes.Buffer
+	if err := CopyRecords(strings.NewReader(javaLog), records, &out); err != nil {
+		t.Fatalf("CopyRecords errored out: %v", err)
+	}
+
+	lines := strings.Split(javaLog, "\n")
+	if expected := strings.Join(lines[1:7], "\n") + "\n"; out.String() != expected {
+		t.Errorf("CopyRecords mismatch. Expected %q, got %q", expected, out.String())
+	}
+}
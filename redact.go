@@ -0,0 +1,393 @@
+package sutils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Detector finds one kind of sensitive value in a line, like email addresses
+// or credit card numbers.
+type Detector struct {
+	// Name names the kind of value, e.g. "email". It shows up in the
+	// replacements of a Redactor.
+	Name string
+
+	// Pattern matches the values. If it has a capturing group that takes
+	// part in the match, only what the first group matches is the value,
+	// so "password=(\S+)" finds the password but leaves "password=" alone.
+	Pattern *regexp.Regexp
+
+	// Valid, if set, is called with every value Pattern matches, and the
+	// value is only found if it returns true. It is for checks a regular
+	// expression cannot do, like checksums.
+	Valid func(value string) bool
+}
+
+// span is a value a Detector found in a line.
+type span struct {
+	start, end int
+	detector   *Detector
+}
+
+// find returns where the detector finds values in line.
+func (d *Detector) find(line string) []span {
+	var spans []span
+
+	for _, m := range d.Pattern.FindAllStringSubmatchIndex(line, -1) {
+		start, end := m[0], m[1]
+		if len(m) > 2 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+
+		if start == end {
+			continue
+		}
+
+		if d.Valid != nil && !d.Valid(line[start:end]) {
+			continue
+		}
+
+		spans = append(spans, span{start, end, d})
+	}
+
+	return spans
+}
+
+// findAll returns the values the detectors find in line, ordered by where
+// they start. Where values overlap, the one starting first wins, and of
+// those starting at the same place the longest.
+func findAll(detectors []Detector, line string) []span {
+	var spans []span
+	for i := range detectors {
+		spans = append(spans, detectors[i].find(line)...)
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+
+		return spans[i].end > spans[j].end
+	})
+
+	kept := spans[:0]
+	for _, s := range spans {
+		if len(kept) > 0 && s.start < kept[len(kept)-1].end {
+			continue
+		}
+
+		kept = append(kept, s)
+	}
+
+	return kept
+}
+
+// LuhnValid reports whether number, ignoring spaces and dashes, is made of
+// digits that pass the Luhn checksum used by credit card numbers.
+func LuhnValid(number string) bool {
+	sum, digits := 0, 0
+
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+
+		if c < '0' || c > '9' {
+			return false
+		}
+
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+
+		sum += d
+		digits++
+	}
+
+	return digits > 0 && sum%10 == 0
+}
+
+// randPasswordShaped reports whether s looks like what RandPassword returns:
+// 12 letters and digits, exactly 4 of them digits.
+func randPasswordShaped(s string) bool {
+	digits := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			digits++
+		}
+	}
+
+	return len(s) == 12 && digits == 4
+}
+
+// ipv6Valid reports whether s is an IPv6 address with at least two groups
+// and a digit, which leaves out scope operators like "a::b" that net.ParseIP
+// accepts.
+func ipv6Valid(s string) bool {
+	if net.ParseIP(s) == nil {
+		return false
+	}
+
+	groups := 0
+	for _, g := range strings.Split(s, ":") {
+		if g != "" {
+			groups++
+		}
+	}
+
+	return groups >= 2 && strings.ContainsAny(s, "0123456789")
+}
+
+// The built-in detectors.
+var (
+	// DetectEmail finds email addresses.
+	DetectEmail = Detector{
+		Name:    "email",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	}
+
+	// DetectIPv4 finds IPv4 addresses.
+	DetectIPv4 = Detector{
+		Name:    "ipv4",
+		Pattern: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`),
+		Valid:   func(s string) bool { return net.ParseIP(s) != nil },
+	}
+
+	// DetectIPv6 finds IPv6 addresses. The pattern takes in whole words
+	// around the colons, so "std::vector" is one candidate, which is not an
+	// address, rather than "d::" in the middle of it.
+	DetectIPv6 = Detector{
+		Name:    "ipv6",
+		Pattern: regexp.MustCompile(`[\w:]*:[\w:]*:[\w:]*(?:\.[\w:]+)*`),
+		Valid:   ipv6Valid,
+	}
+
+	// DetectCreditCard finds credit card numbers of 13 to 19 digits,
+	// optionally grouped by spaces or dashes, that pass the Luhn checksum.
+	DetectCreditCard = Detector{
+		Name:    "card",
+		Pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		Valid:   LuhnValid,
+	}
+
+	// DetectJWT finds JSON Web Tokens.
+	DetectJWT = Detector{
+		Name:    "jwt",
+		Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	}
+
+	// DetectAPIKey finds API keys in the formats of well-known services
+	// (AWS, GitHub, Stripe, Slack, Google) and bearer tokens.
+	DetectAPIKey = Detector{
+		Name:    "apikey",
+		Pattern: regexp.MustCompile(`\b(?:AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36}|[sr]k_(?:live|test)_[A-Za-z0-9]{16,}|xox[abprs]-[A-Za-z0-9-]{10,}|AIza[0-9A-Za-z_-]{35})\b|(?i:bearer)\s+([A-Za-z0-9._~+/-]{16,}=*)`),
+	}
+
+	// DetectPassword finds the values of password, secret and token
+	// settings, like "password=hunter2" or `"secret": "x"`.
+	DetectPassword = Detector{
+		Name:    "password",
+		Pattern: regexp.MustCompile(`(?i)(?:pass(?:word)?|passwd|pwd|secret|token|api[_-]?key)["']?\s*[:=]\s*["']?([^\s"'&,;]+)`),
+	}
+
+	// DetectRandPassword finds words shaped like the passwords RandPassword
+	// generates: 12 letters and digits, exactly 4 of them digits.
+	DetectRandPassword = Detector{
+		Name:    "password",
+		Pattern: regexp.MustCompile(`\b[A-Za-z0-9]{12}\b`),
+		Valid:   randPasswordShaped,
+	}
+)
+
+// DefaultDetectors returns the built-in detectors.
+func DefaultDetectors() []Detector {
+	return []Detector{
+		DetectPassword, DetectJWT, DetectAPIKey, DetectEmail,
+		DetectCreditCard, DetectIPv4, DetectIPv6, DetectRandPassword,
+	}
+}
+
+// RedactMode is how a Redactor replaces the values it finds.
+type RedactMode int
+
+const (
+	// RedactMask replaces a value with its kind, like "[REDACTED email]".
+	RedactMask RedactMode = iota
+
+	// RedactHash replaces a value with its kind and a hash of it, like
+	// "[email:1f2e3d4c5b6a]", so equal values can still be told apart from
+	// others. Without a Key, values with few possibilities, like IP
+	// addresses, can be recovered from their hashes.
+	RedactHash
+
+	// RedactPseudonym replaces a value with its kind and a number, like
+	// "[email-3]". The same value gets the same number for as long as the
+	// Redactor is used.
+	RedactPseudonym
+)
+
+// Redactor replaces the sensitive values its detectors find in lines. It
+// is safe for concurrent use.
+type Redactor struct {
+	// Detectors find the values to replace.
+	Detectors []Detector
+
+	// Mode is how values are replaced.
+	Mode RedactMode
+
+	// Key is the key of the HMAC used by RedactHash.
+	Key []byte
+
+	mu         sync.Mutex
+	pseudonyms map[string]string
+	counts     map[string]int
+}
+
+// NewRedactor returns a Redactor using the built-in detectors.
+func NewRedactor(mode RedactMode) *Redactor {
+	return &Redactor{Detectors: DefaultDetectors(), Mode: mode}
+}
+
+// AddRule adds a custom detector for the values pattern matches, which
+// replacements call name.
+func (r *Redactor) AddRule(name string, pattern *regexp.Regexp) {
+	r.Detectors = append(r.Detectors, Detector{Name: name, Pattern: pattern})
+}
+
+// replacement returns what the value found by d is replaced with.
+func (r *Redactor) replacement(d *Detector, value string) string {
+	switch r.Mode {
+	case RedactHash:
+		mac := hmac.New(sha256.New, r.Key)
+		mac.Write([]byte(value))
+
+		return "[" + d.Name + ":" + hex.EncodeToString(mac.Sum(nil))[:12] + "]"
+	case RedactPseudonym:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if r.pseudonyms == nil {
+			r.pseudonyms, r.counts = make(map[string]string), make(map[string]int)
+		}
+
+		key := d.Name + "\x00" + value
+		if p, ok := r.pseudonyms[key]; ok {
+			return p
+		}
+
+		r.counts[d.Name]++
+		p := "[" + d.Name + "-" + strconv.Itoa(r.counts[d.Name]) + "]"
+		r.pseudonyms[key] = p
+
+		return p
+	}
+
+	return "[REDACTED " + d.Name + "]"
+}
+
+// Redact returns line with the values the detectors find in it replaced.
+func (r *Redactor) Redact(line string) string {
+	spans := findAll(r.Detectors, line)
+	if len(spans) == 0 {
+		return line
+	}
+
+	var sb strings.Builder
+
+	prev := 0
+	for _, s := range spans {
+		sb.WriteString(line[prev:s.start])
+		sb.WriteString(r.replacement(s.detector, line[s.start:s.end]))
+		prev = s.end
+	}
+
+	sb.WriteString(line[prev:])
+
+	return sb.String()
+}
+
+// Copy copies the lines of "from" to "to" redacted, like CopyLines does.
+func (r *Redactor) Copy(from io.Reader, to io.Writer) error {
+	lr := lineReader(from)
+	w := &lineWriter{w: to}
+
+	for lr.Scan() {
+		if err := w.write(r.Redact(lr.Text())); err != nil {
+			return err
+		}
+	}
+
+	return lr.Err()
+}
+
+// Stage returns a pipeline stage that redacts every line.
+func (r *Redactor) Stage() Stage {
+	return func(line Line, emit func(Line) error) error {
+		line.Text = r.Redact(line.Text)
+		return emit(line)
+	}
+}
+
+// Writer returns a writer that redacts what is written to it line by line
+// and writes it on to w, keeping the line endings as they are. A line is
+// written on once its newline is, or when the writer is closed, which does
+// not close w.
+func (r *Redactor) Writer(w io.Writer) io.WriteCloser {
+	return &redactingWriter{r: r, w: w}
+}
+
+type redactingWriter struct {
+	r       *Redactor
+	w       io.Writer
+	pending []byte
+}
+
+func (rw *redactingWriter) Write(p []byte) (int, error) {
+	rw.pending = append(rw.pending, p...)
+
+	for {
+		i := bytes.IndexByte(rw.pending, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+
+		line := rw.pending[:i]
+		ending := "\n"
+		if bytes.HasSuffix(line, []byte("\r")) {
+			line, ending = line[:len(line)-1], "\r\n"
+		}
+
+		if _, err := io.WriteString(rw.w, rw.r.Redact(string(line))+ending); err != nil {
+			return 0, err
+		}
+
+		rw.pending = rw.pending[i+1:]
+	}
+}
+
+func (rw *redactingWriter) Close() error {
+	if len(rw.pending) == 0 {
+		return nil
+	}
+
+	line := string(rw.pending)
+	rw.pending = nil
+
+	_, err := io.WriteString(rw.w, rw.r.Redact(line))
+
+	return err
+}
@@ -0,0 +1,216 @@
+package sutils
+
+import (
+	"bytes"
+	"io"
+)
+
+// reverseBlockSize is how much a ReverseLineReader reads at a time.
+const reverseBlockSize = 64 * 1024
+
+// ReverseLineReader reads the lines of its input backwards, from the last
+// one to the first. Lines end in "\n" or "\r\n" like for a LineReader, a
+// final newline does not start another (empty) line, and a last line
+// without a newline is returned as well.
+type ReverseLineReader struct {
+	r io.ReaderAt
+
+	// chunk holds the bytes of the input from start up to the end of the
+	// part that has not been returned yet.
+	chunk []byte
+	start int64
+	block int
+
+	line    []byte
+	offset  int64
+	started bool
+	done    bool
+	err     error
+}
+
+// NewReverseLineReader returns a ReverseLineReader reading the first size
+// bytes of r.
+func NewReverseLineReader(r io.ReaderAt, size int64) *ReverseLineReader {
+	return &ReverseLineReader{r: r, start: size, block: reverseBlockSize, done: size == 0}
+}
+
+// NewReverseLineReaderSeeker returns a ReverseLineReader reading rs, which
+// it reads by seeking around in it. rs must not be used by anything else
+// while the lines are being read.
+func NewReverseLineReaderSeeker(rs io.ReadSeeker) (*ReverseLineReader, error) {
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReverseLineReader(&seekerAt{rs}, size), nil
+}
+
+// seekerAt reads from an io.ReadSeeker as if it was an io.ReaderAt.
+type seekerAt struct {
+	rs io.ReadSeeker
+}
+
+func (s *seekerAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := s.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return io.ReadFull(s.rs, p)
+}
+
+// fill prepends the block of input before the current chunk to it. It
+// returns false if there is nothing left to read.
+func (l *ReverseLineReader) fill() bool {
+	if l.start == 0 {
+		return false
+	}
+
+	n := int64(l.block)
+	if n > l.start {
+		n = l.start
+	}
+
+	// Lines longer than a block are read in ever larger blocks, to keep
+	// the copying linear.
+	if l.block < 64*reverseBlockSize {
+		l.block *= 2
+	}
+
+	buf := make([]byte, int(n)+len(l.chunk))
+	if _, err := l.r.ReadAt(buf[:n], l.start-n); err != nil && err != io.EOF {
+		l.err = &LineError{Op: OpRead, Offset: l.start - n, Err: err}
+		return false
+	}
+
+	copy(buf[n:], l.chunk)
+	l.chunk = buf
+	l.start -= n
+
+	return true
+}
+
+// Scan moves to the line before the current one, which is then available
+// through Bytes or Text. It returns false once the first line has been
+// read, or on an error, which is then returned by Err.
+func (l *ReverseLineReader) Scan() bool {
+	if l.done || l.err != nil {
+		return false
+	}
+
+	if !l.started {
+		// First call: drop the newline that ends the last line.
+		l.started = true
+		if !l.fill() {
+			return false
+		}
+
+		l.chunk = bytes.TrimSuffix(l.chunk, []byte("\n"))
+	}
+
+	for {
+		if i := bytes.LastIndexByte(l.chunk, '\n'); i >= 0 {
+			l.line = l.chunk[i+1:]
+			l.offset = l.start + int64(i) + 1
+			l.chunk = l.chunk[:i]
+
+			break
+		}
+
+		if !l.fill() {
+			if l.err != nil {
+				return false
+			}
+
+			l.line = l.chunk
+			l.offset = l.start
+			l.chunk = nil
+			l.done = true
+
+			break
+		}
+	}
+
+	l.line = bytes.TrimSuffix(l.line, []byte("\r"))
+
+	return true
+}
+
+// Bytes returns the current line without its line ending. The slice is only
+// valid until the next call to Scan.
+func (l *ReverseLineReader) Bytes() []byte {
+	return l.line
+}
+
+// Text returns the current line without its line ending.
+func (l *ReverseLineReader) Text() string {
+	return string(l.line)
+}
+
+// Offset returns the byte offset at which the current line starts.
+func (l *ReverseLineReader) Offset() int64 {
+	return l.offset
+}
+
+// Err returns the first error encountered by Scan as a *LineError, or nil
+// if the input was read to its beginning.
+func (l *ReverseLineReader) Err() error {
+	return l.err
+}
+
+// Tail writes the last n lines of the first size bytes of r to w, in their
+// original order. Like CopyLines, it ends every line with "\n".
+func Tail(r io.ReaderAt, size int64, n int, w io.Writer) error {
+	rr := NewReverseLineReader(r, size)
+
+	var lines [][]byte
+	var offsets []int64
+	for len(lines) < n && rr.Scan() {
+		lines = append(lines, append([]byte(nil), rr.Bytes()...))
+		offsets = append(offsets, rr.Offset())
+	}
+
+	if err := rr.Err(); err != nil {
+		return err
+	}
+
+	// The numbers of the lines are not known without reading the whole
+	// input, so write errors only have the offsets of the lines.
+	for i := len(lines) - 1; i >= 0; i-- {
+		if _, err := w.Write(append(lines[i], '\n')); err != nil {
+			return &LineError{Op: OpWrite, Offset: offsets[i], Err: err}
+		}
+	}
+
+	return nil
+}
+
+// LastMatch is a line found by FindLastWith.
+type LastMatch struct {
+	// Offset is the byte offset at which the line starts. CountLinesAt on
+	// the input up to Offset gives the number of lines before it.
+	Offset int64
+
+	// Text is the line, without its line ending.
+	Text string
+}
+
+// FindLastWith searches the first size bytes of r backwards for the last
+// line for which find returns true for one of the needles, like FindWith
+// would. It stops at the first such line it reads, so only the input after
+// it is read. The boolean result is false if no line matched.
+func FindLastWith(find func(string, string) bool, r io.ReaderAt, size int64, needles []string) (LastMatch, bool, error) {
+	rr := NewReverseLineReader(r, size)
+
+	for rr.Scan() {
+		line := rr.Text()
+
+		for _, needle := range needles {
+			if needle != "" && find(line, needle) {
+				return LastMatch{Offset: rr.Offset(), Text: line}, true, nil
+			}
+		}
+	}
+
+	return LastMatch{}, false, rr.Err()
+}
@@ -0,0 +1,130 @@
+package sutils
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReverseLineReader(t *testing.T) {
+	tests := []struct {
+		Input   string
+		Lines   []string
+		Offsets []int64
+	}{
+		{"", nil, nil},
+		{"\n", []string{""}, []int64{0}},
+		{"one", []string{"one"}, []int64{0}},
+		{"one\n", []string{"one"}, []int64{0}},
+		{"one\ntwo", []string{"two", "one"}, []int64{4, 0}},
+		{"one\r\ntwo\r\n", []string{"two", "one"}, []int64{5, 0}},
+		{"one\n\n\nfour\n", []string{"four", "", "", "one"}, []int64{6, 5, 4, 0}},
+		{"\r\n\r\n", []string{"", ""}, []int64{2, 0}},
+	}
+
+	for _, test := range tests {
+		r := NewReverseLineReader(strings.NewReader(test.Input), int64(len(test.Input)))
+
+		var (
+			lines   []string
+			offsets []int64
+		)
+
+		for r.Scan() {
+			lines = append(lines, r.Text())
+			offsets = append(offsets, r.Offset())
+		}
+
+		if err := r.Err(); err != nil {
+			t.Errorf("ReverseLineReader(%q) errored out: %v", test.Input, err)
+		}
+
+		if !reflect.DeepEqual(test.Lines, lines) || !reflect.DeepEqual(test.Offsets, offsets) {
+			t.Errorf("ReverseLineReader(%q) mismatch. Expected %q at %v, got %q at %v", test.Input, test.Lines, test.Offsets, lines, offsets)
+		}
+	}
+}
+
+func TestReverseLineReaderLongLines(t *testing.T) {
+	long := strings.Repeat("x", 3*reverseBlockSize+17)
+	input := "first\n" + long + "\r\n" + long + "y\nlast"
+
+	r, err := NewReverseLineReaderSeeker(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("NewReverseLineReaderSeeker errored out: %v", err)
+	}
+
+	var lines []string
+	for r.Scan() {
+		lines = append(lines, r.Text())
+	}
+
+	expected := []string{"last", long + "y", long, "first"}
+	if !reflect.DeepEqual(expected, lines) {
+		t.Errorf("ReverseLineReader mismatch on long lines, got %d lines", len(lines))
+	}
+}
+
+func TestTail(t *testing.T) {
+	tests := []struct {
+		Input    string
+		N        int
+		Expected string
+	}{
+		{"LineOne\nLineTwo\nLineThree\n", 2, "LineTwo\nLineThree\n"},
+		{"LineOne\r\nLineTwo\r\nLineThree", 1, "LineThree\n"},
+		{"LineOne\nLineTwo\n", 5, "LineOne\nLineTwo\n"},
+		{"LineOne\nLineTwo\n", 0, ""},
+		{"", 3, ""},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+
+		if err := Tail(strings.NewReader(test.Input), int64(len(test.Input)), test.N, &out); err != nil {
+			t.Errorf("Tail(%q, %d) errored out: %v", test.Input, test.N, err)
+		}
+
+		if msg, ok := expect(test.Expected, out.String()); !ok {
+			t.Error(msg)
+		}
+	}
+}
+
+func TestTailWriteError(t *testing.T) {
+	const input = "line one\nline two\nline three\n"
+
+	err := Tail(strings.NewReader(input), int64(len(input)), 2, &failingWriter{n: 1})
+
+	var lerr *LineError
+	if !errors.As(err, &lerr) || !errors.Is(err, errInjected) {
+		t.Fatalf("expected a *LineError wrapping the injected error, got %v", err)
+	}
+
+	if lerr.Op != OpWrite || lerr.Line != 0 || lerr.Offset != 18 {
+		t.Errorf("expected write failure at offset 18 with no line number, got %+v", lerr)
+	}
+}
+
+func TestFindLastWith(t *testing.T) {
+	input := "ERROR one\nfine\nERROR two\r\nfine again"
+
+	m, ok, err := FindLastWith(strings.Contains, strings.NewReader(input), int64(len(input)), []string{"ERROR"})
+	if err != nil || !ok {
+		t.Fatalf("FindLastWith found nothing: %v", err)
+	}
+
+	if m.Text != "ERROR two" || m.Offset != 15 {
+		t.Errorf("FindLastWith mismatch. Expected \"ERROR two\" at 15, got %q at %d", m.Text, m.Offset)
+	}
+
+	if lines, _ := CountLinesAt(strings.NewReader(input), m.Offset); lines != 2 {
+		t.Errorf("expected 2 lines before the match, got %d", lines)
+	}
+
+	if _, ok, _ := FindLastWith(strings.Contains, strings.NewReader(input), int64(len(input)), []string{"missing"}); ok {
+		t.Errorf("FindLastWith matched a missing needle")
+	}
+}
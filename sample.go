@@ -0,0 +1,183 @@
+package sutils
+
+import (
+	"io"
+	"math/rand"
+	"sort"
+)
+
+// The sampling functions draw their random numbers from a source seeded with
+// seed, so the same seed and input give the same sample. Pass something like
+// time.Now().UnixNano() for a different sample every time.
+
+// sampled is a line kept in a sample.
+type sampled struct {
+	line int
+	text string
+}
+
+// reservoir keeps a uniform random sample of k of the lines offered to it,
+// using Algorithm R.
+type reservoir struct {
+	k     int
+	seen  int
+	lines []sampled
+}
+
+func (s *reservoir) offer(rng *rand.Rand, l sampled) {
+	s.seen++
+
+	if len(s.lines) < s.k {
+		s.lines = append(s.lines, l)
+		return
+	}
+
+	if i := rng.Intn(s.seen); i < s.k {
+		s.lines[i] = l
+	}
+}
+
+// sample runs the reservoirs over the lines of "from", offering every line to
+// the reservoir of its stratum, and returns the lines kept, in order. The
+// text of the lines is only kept if keepText is true.
+func sample(from io.Reader, k int, stratum KeyFunc, seed int64, keepText bool) ([]sampled, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	strata := make(map[string]*reservoir)
+
+	r := lineReader(from)
+	for r.Scan() {
+		key := ""
+		if stratum != nil {
+			key = stratum(r.Text())
+		}
+
+		s, ok := strata[key]
+		if !ok {
+			s = &reservoir{k: k}
+			strata[key] = s
+		}
+
+		l := sampled{line: r.Line()}
+		if keepText {
+			l.text = r.Text()
+		}
+
+		s.offer(rng, l)
+	}
+
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	var lines []sampled
+	for _, s := range strata {
+		lines = append(lines, s.lines...)
+	}
+
+	sort.Slice(lines, func(i, j int) bool { return lines[i].line < lines[j].line })
+
+	return lines, nil
+}
+
+func sampledLines(lines []sampled) []int {
+	nums := make([]int, len(lines))
+	for i, l := range lines {
+		nums[i] = l.line
+	}
+
+	return nums
+}
+
+func writeSampled(to io.Writer, lines []sampled) error {
+	for _, l := range lines {
+		if _, err := io.WriteString(to, l.text+"\n"); err != nil {
+			return &LineError{Op: OpWrite, Line: l.line, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// SampleLines returns the numbers of k lines of "from" chosen uniformly at
+// random, in order, reading "from" only once and without knowing its length
+// in advance. If "from" has k lines or fewer, all of them are returned. The
+// line numbers can be passed on to CopyLines.
+func SampleLines(from io.Reader, k int, seed int64) ([]int, error) {
+	lines, err := sample(from, k, nil, seed, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return sampledLines(lines), nil
+}
+
+// CopySample writes k lines of "from" chosen like SampleLines does to "to",
+// in order, ending every line with "\n". It holds the sampled lines in
+// memory until all of "from" has been read.
+func CopySample(from io.Reader, k int, seed int64, to io.Writer) error {
+	lines, err := sample(from, k, nil, seed, true)
+	if err != nil {
+		return err
+	}
+
+	return writeSampled(to, lines)
+}
+
+// SampleStratified returns the numbers of k lines of "from" chosen uniformly
+// at random for every stratum, in order. The stratum of a line is what
+// stratum returns for it, e.g. its log level, so rare kinds of lines are not
+// drowned out by common ones.
+func SampleStratified(from io.Reader, k int, stratum KeyFunc, seed int64) ([]int, error) {
+	lines, err := sample(from, k, stratum, seed, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return sampledLines(lines), nil
+}
+
+// CopyStratifiedSample writes the lines SampleStratified chooses to "to", in
+// order, ending every line with "\n". It holds the sampled lines in memory
+// until all of "from" has been read.
+func CopyStratifiedSample(from io.Reader, k int, stratum KeyFunc, seed int64, to io.Writer) error {
+	lines, err := sample(from, k, stratum, seed, true)
+	if err != nil {
+		return err
+	}
+
+	return writeSampled(to, lines)
+}
+
+// SamplePercent returns the numbers of the lines of "from" chosen by keeping
+// every line with a probability of percent/100, in order. The size of the
+// sample varies around that percentage of the lines.
+func SamplePercent(from io.Reader, percent float64, seed int64) ([]int, error) {
+	rng := rand.New(rand.NewSource(seed))
+
+	return findLines(from, func(string) bool {
+		return rng.Float64()*100 < percent
+	})
+}
+
+// CopySamplePercent writes the lines SamplePercent chooses to "to" as they
+// are read, ending every line with "\n".
+func CopySamplePercent(from io.Reader, percent float64, seed int64, to io.Writer) error {
+	rng := rand.New(rand.NewSource(seed))
+
+	r := lineReader(from)
+	for r.Scan() {
+		if rng.Float64()*100 >= percent {
+			continue
+		}
+
+		if err := writeLine(to, r); err != nil {
+			return err
+		}
+	}
+
+	return r.Err()
+}
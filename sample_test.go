@@ -0,0 +1,128 @@
+package sutils
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSampleLines(t *testing.T) {
+	input := numberedLines(1, 1000)
+
+	lines, err := SampleLines(strings.NewReader(input), 10, 42)
+	if err != nil {
+		t.Fatalf("SampleLines errored out: %v", err)
+	}
+
+	if len(lines) != 10 || !sort.IntsAreSorted(lines) {
+		t.Errorf("expected 10 sorted line numbers, got %v", lines)
+	}
+
+	again, _ := SampleLines(strings.NewReader(input), 10, 42)
+	if !reflect.DeepEqual(lines, again) {
+		t.Errorf("same seed gave different samples: %v and %v", lines, again)
+	}
+
+	other, _ := SampleLines(strings.NewReader(input), 10, 43)
+	if reflect.DeepEqual(lines, other) {
+		t.Errorf("different seeds gave the same sample %v", lines)
+	}
+
+	var expected, got bytes.Buffer
+	if err := CopyLines(strings.NewReader(input), lines, &expected); err != nil {
+		t.Fatalf("CopyLines errored out: %v", err)
+	}
+
+	if err := CopySample(strings.NewReader(input), 10, 42, &got); err != nil {
+		t.Fatalf("CopySample errored out: %v", err)
+	}
+
+	if got.String() != expected.String() {
+		t.Errorf("CopySample mismatch. Expected %q, got %q", expected.String(), got.String())
+	}
+
+	all, _ := SampleLines(strings.NewReader("a\nb\nc\n"), 10, 1)
+	if !reflect.DeepEqual([]int{1, 2, 3}, all) {
+		t.Errorf("sampling more lines than there are: got %v", all)
+	}
+}
+
+func TestSampleLinesUniform(t *testing.T) {
+	input := numberedLines(1, 10)
+	counts := make([]int, 11)
+
+	for seed := int64(0); seed < 2000; seed++ {
+		lines, err := SampleLines(strings.NewReader(input), 3, seed)
+		if err != nil {
+			t.Fatalf("SampleLines errored out: %v", err)
+		}
+
+		for _, l := range lines {
+			counts[l]++
+		}
+	}
+
+	// Every line should be picked about 2000*3/10 = 600 times.
+	for l := 1; l <= 10; l++ {
+		if counts[l] < 500 || counts[l] > 700 {
+			t.Errorf("line %d picked %d times, expected about 600", l, counts[l])
+		}
+	}
+}
+
+func TestSampleStratified(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 1000; i++ {
+		sb.WriteString("INFO ok\n")
+
+		if i%100 == 0 {
+			sb.WriteString("ERROR rare\n")
+		}
+	}
+
+	level := func(line string) string { return strings.Fields(line)[0] }
+
+	var buf bytes.Buffer
+	if err := CopyStratifiedSample(strings.NewReader(sb.String()), 5, level, 7, &buf); err != nil {
+		t.Fatalf("CopyStratifiedSample errored out: %v", err)
+	}
+
+	if n := strings.Count(buf.String(), "ERROR"); n != 5 {
+		t.Errorf("expected 5 ERROR lines, got %d", n)
+	}
+
+	if n := strings.Count(buf.String(), "INFO"); n != 5 {
+		t.Errorf("expected 5 INFO lines, got %d", n)
+	}
+
+	lines, err := SampleStratified(strings.NewReader(sb.String()), 5, level, 7)
+	if err != nil || len(lines) != 10 {
+		t.Errorf("SampleStratified = %v, %v; expected 10 lines", lines, err)
+	}
+}
+
+func TestSamplePercent(t *testing.T) {
+	input := numberedLines(1, 10000)
+
+	lines, err := SamplePercent(strings.NewReader(input), 10, 3)
+	if err != nil {
+		t.Fatalf("SamplePercent errored out: %v", err)
+	}
+
+	if len(lines) < 900 || len(lines) > 1100 {
+		t.Errorf("10%% of 10000 lines sampled %d of them", len(lines))
+	}
+
+	var expected, got bytes.Buffer
+	CopyLines(strings.NewReader(input), lines, &expected)
+
+	if err := CopySamplePercent(strings.NewReader(input), 10, 3, &got); err != nil {
+		t.Fatalf("CopySamplePercent errored out: %v", err)
+	}
+
+	if got.String() != expected.String() {
+		t.Errorf("CopySamplePercent and SamplePercent chose different lines")
+	}
+}
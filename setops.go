@@ -0,0 +1,732 @@
+package sutils
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The set operations compare lines by the key SortOptions give them: the
+// whole line or one of its fields, optionally ignoring case. The sorted
+// variants expect both inputs to be sorted by SortLines with the same
+// options, and compare keys the way it does; the others compare keys for
+// equality only, and ignore Numeric, Reverse and Stable.
+
+// keyOrder compares the keys of two lines the way the sort did, but without
+// falling back to comparing their text.
+func (o *SortOptions) keyOrder(a, b sortLine) int {
+	keys := *o
+	keys.Stable = true
+
+	return keys.compare(a, b)
+}
+
+// fields splits a line into fields the way the options do.
+func (o *SortOptions) fields(text string) []string {
+	if o.Separator == "" {
+		return strings.Fields(text)
+	}
+
+	return strings.Split(text, o.Separator)
+}
+
+// joinLines joins a line of the first input with a line of the second one:
+// the line of the first input, followed by the fields of the second one
+// other than the key.
+func (o *SortOptions) joinLines(a, b string) string {
+	if o.Field <= 0 {
+		return a
+	}
+
+	sep := o.Separator
+	if sep == "" {
+		sep = " "
+	}
+
+	out := a
+	for i, f := range o.fields(b) {
+		if i != o.Field-1 {
+			out += sep + f
+		}
+	}
+
+	return out
+}
+
+// sortedInput reads a sorted input line by line.
+type sortedInput struct {
+	r    *LineReader
+	line sortLine
+	ok   bool
+}
+
+func newSortedInput(r io.Reader, o *SortOptions) *sortedInput {
+	in := &sortedInput{r: lineReader(r)}
+	in.next(o)
+
+	return in
+}
+
+func (in *sortedInput) next(o *SortOptions) {
+	in.ok = in.r.Scan()
+	if in.ok {
+		in.line = o.line(in.r.Text())
+	}
+}
+
+// sortedMerge walks two sorted inputs side by side, calling fn with the
+// current lines and the order of their keys. A line is nil once its input
+// has ended; fn returns which of the inputs to advance.
+func sortedMerge(a, b io.Reader, opts SortOptions, fn func(x, y *sortLine, cmp int) (nextA, nextB bool, err error)) error {
+	o := &opts
+	ina, inb := newSortedInput(a, o), newSortedInput(b, o)
+
+	for ina.ok || inb.ok {
+		var x, y *sortLine
+		cmp := 0
+
+		switch {
+		case !inb.ok:
+			x, cmp = &ina.line, -1
+		case !ina.ok:
+			y, cmp = &inb.line, 1
+		default:
+			x, y = &ina.line, &inb.line
+			cmp = o.keyOrder(*x, *y)
+		}
+
+		nextA, nextB, err := fn(x, y, cmp)
+		if err != nil {
+			return err
+		}
+
+		if nextA {
+			ina.next(o)
+		}
+
+		if nextB {
+			inb.next(o)
+		}
+	}
+
+	if err := ina.r.Err(); err != nil {
+		return err
+	}
+
+	return inb.r.Err()
+}
+
+// IntersectSorted writes the lines of the sorted input a whose key is also
+// in the sorted input b to "to", the way "comm -12" does. Every line of b
+// matches only one line of a, so a key that is in a three times and in b
+// twice is written twice.
+func IntersectSorted(a, b io.Reader, opts SortOptions, to io.Writer) error {
+	w := &lineWriter{w: to}
+
+	return sortedMerge(a, b, opts, func(x, y *sortLine, cmp int) (bool, bool, error) {
+		if cmp == 0 {
+			return true, true, w.write(x.text)
+		}
+
+		return cmp < 0, cmp > 0, nil
+	})
+}
+
+// DifferenceSorted writes the lines of the sorted input a whose key is not
+// in the sorted input b to "to", the way "comm -23" does. Like for
+// IntersectSorted, every line of b cancels out only one line of a.
+func DifferenceSorted(a, b io.Reader, opts SortOptions, to io.Writer) error {
+	w := &lineWriter{w: to}
+
+	return sortedMerge(a, b, opts, func(x, y *sortLine, cmp int) (bool, bool, error) {
+		switch {
+		case x == nil:
+			return false, true, nil
+		case cmp < 0:
+			return true, false, w.write(x.text)
+		}
+
+		return cmp == 0, true, nil
+	})
+}
+
+// UnionSorted merges the sorted inputs a and b into "to", writing lines
+// whose key is in both only once, from a. The output is sorted as well.
+func UnionSorted(a, b io.Reader, opts SortOptions, to io.Writer) error {
+	w := &lineWriter{w: to}
+
+	return sortedMerge(a, b, opts, func(x, y *sortLine, cmp int) (bool, bool, error) {
+		if cmp > 0 {
+			return false, true, w.write(y.text)
+		}
+
+		return true, cmp == 0, w.write(x.text)
+	})
+}
+
+// JoinSorted joins the sorted inputs a and b on their key, the way "join"
+// does: for every pair of lines of a and b with the same key, it writes the
+// line of a followed by the fields of the line of b other than the key,
+// separated by opts.Separator, or a space.
+func JoinSorted(a, b io.Reader, opts SortOptions, to io.Writer) error {
+	o := &opts
+	w := &lineWriter{w: to}
+
+	// group holds the lines of b with the key of the current line of a.
+	var group []sortLine
+
+	return sortedMerge(a, b, opts, func(x, y *sortLine, cmp int) (bool, bool, error) {
+		if x == nil {
+			return false, true, nil
+		}
+
+		// Collect all the lines of b with the key first.
+		if cmp == 0 {
+			if len(group) > 0 && o.keyOrder(group[0], *y) != 0 {
+				group = group[:0]
+			}
+
+			group = append(group, *y)
+
+			return false, true, nil
+		}
+
+		if len(group) > 0 && o.keyOrder(group[0], *x) == 0 {
+			for _, g := range group {
+				if err := w.write(o.joinLines(x.text, g.text)); err != nil {
+					return false, false, err
+				}
+			}
+
+			return true, false, nil
+		}
+
+		group = group[:0]
+
+		return cmp < 0, cmp > 0, nil
+	})
+}
+
+// lineWriter writes lines, ending them with "\n", and counts them for the
+// errors it returns.
+type lineWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *lineWriter) write(text string) error {
+	l.n++
+
+	if _, err := io.WriteString(l.w, text+"\n"); err != nil {
+		return &LineError{Op: OpWrite, Line: l.n, Err: err}
+	}
+
+	return nil
+}
+
+// setOp is a set operation done by hashing.
+type setOp int
+
+const (
+	opIntersect setOp = iota
+	opDifference
+	opUnion
+	opJoin
+)
+
+// Intersect writes the lines of a whose key is also in b to "to", in the
+// order of a. Unlike for IntersectSorted, the inputs need not be sorted, and
+// every line of a whose key is in b is written, however often the key is in
+// b.
+//
+// The lines of b are held in memory, up to opts.Memory bytes of them. Beyond
+// that, both inputs are split into partitions by the hash of their keys,
+// which are written to temporary files and processed one at a time, holding
+// only the keys of b. Partitions that still do not fit are split further.
+func Intersect(a, b io.Reader, opts SortOptions, to io.Writer) error {
+	return hashSetOp(opIntersect, a, b, opts, to)
+}
+
+// Difference writes the lines of a whose key is not in b to "to", in the
+// order of a. The inputs need not be sorted, and are processed like for
+// Intersect.
+func Difference(a, b io.Reader, opts SortOptions, to io.Writer) error {
+	return hashSetOp(opDifference, a, b, opts, to)
+}
+
+// Union writes the lines of a to "to", followed by the lines of b whose key
+// is not in a. The inputs need not be sorted, and are processed like for
+// Intersect.
+func Union(a, b io.Reader, opts SortOptions, to io.Writer) error {
+	return hashSetOp(opUnion, a, b, opts, to)
+}
+
+// Join joins a and b on their key like JoinSorted does, writing the joined
+// lines in the order of a, and for every line of a in the order of b. The
+// inputs need not be sorted, and are processed like for Intersect, except
+// that the lines of b are held rather than their keys. Lines of b sharing a
+// key that do not fit in memory together are joined a part at a time.
+func Join(a, b io.Reader, opts SortOptions, to io.Writer) error {
+	return hashSetOp(opJoin, a, b, opts, to)
+}
+
+// seqLine is a line with the number it is ordered by in the output.
+type seqLine struct {
+	seq  int
+	text string
+}
+
+// hashTable holds the lines of the second input of a set operation by key,
+// or with keysOnly, only their keys, once each.
+type hashTable struct {
+	op       setOp
+	opts     *SortOptions
+	keysOnly bool
+
+	keys  map[string][]int
+	lines []seqLine
+	inA   map[string]bool
+	size  int64
+}
+
+func newHashTable(op setOp, opts *SortOptions, keysOnly bool) *hashTable {
+	return &hashTable{op: op, opts: opts, keysOnly: keysOnly, keys: make(map[string][]int), inA: make(map[string]bool)}
+}
+
+func (t *hashTable) add(seq int, text string) {
+	key := t.opts.line(text).key
+
+	if t.keysOnly {
+		if _, ok := t.keys[key]; !ok {
+			t.keys[key] = nil
+			t.size += int64(len(key))
+		}
+
+		return
+	}
+
+	t.keys[key] = append(t.keys[key], len(t.lines))
+	t.lines = append(t.lines, seqLine{seq, text})
+	t.size += int64(len(text))
+}
+
+// probe runs the operation for a line of the first input.
+func (t *hashTable) probe(line seqLine, emit func(seqLine) error) error {
+	key := t.opts.line(line.text).key
+	matches, ok := t.keys[key]
+
+	switch t.op {
+	case opIntersect:
+		if ok {
+			return emit(line)
+		}
+	case opDifference:
+		if !ok {
+			return emit(line)
+		}
+	case opUnion:
+		if ok {
+			t.inA[key] = true
+		}
+
+		return emit(line)
+	case opJoin:
+		for _, i := range matches {
+			if err := emit(seqLine{line.seq, t.opts.joinLines(line.text, t.lines[i].text)}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// finish writes the lines of the second input a union adds, ordering them
+// after the base-th line.
+func (t *hashTable) finish(base int, emit func(seqLine) error) error {
+	if t.op != opUnion {
+		return nil
+	}
+
+	for _, l := range t.lines {
+		if t.inA[t.opts.line(l.text).key] {
+			continue
+		}
+
+		if err := emit(seqLine{base + l.seq, l.text}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hashSetOp(op setOp, a, b io.Reader, opts SortOptions, to io.Writer) error {
+	limit := opts.Memory
+	if limit <= 0 {
+		limit = DefaultSortMemory
+	}
+
+	t := newHashTable(op, &opts, false)
+
+	rb := lineReader(b)
+	for t.size < limit && rb.Scan() {
+		t.add(rb.Line(), rb.Text())
+	}
+
+	if err := rb.Err(); err != nil {
+		return err
+	}
+
+	if t.size >= limit {
+		return spilledSetOp(t, limit, lineReader(a), rb, to)
+	}
+
+	w := &lineWriter{w: to}
+	emit := func(l seqLine) error { return w.write(l.text) }
+
+	ra := lineReader(a)
+	for ra.Scan() {
+		if err := t.probe(seqLine{ra.Line(), ra.Text()}, emit); err != nil {
+			return err
+		}
+	}
+
+	if err := ra.Err(); err != nil {
+		return err
+	}
+
+	return t.finish(0, emit)
+}
+
+// maxPartitionLevel is how many times a spilled set operation splits a
+// partition that is still too large to fit in memory.
+const maxPartitionLevel = 8
+
+// partitions are the temporary files a spilled set operation splits an input
+// into. Their lines are the number of the line in the input, a tab, and the
+// line. The files are only created once lines are written to them.
+type partitions struct {
+	dir     string
+	level   int
+	files   []*os.File
+	writers []*bufio.Writer
+	sizes   []int64
+}
+
+// newPartitions returns n partitions at the given level of splitting.
+func newPartitions(dir string, level, n int) *partitions {
+	return &partitions{
+		dir:     dir,
+		level:   level,
+		files:   make([]*os.File, n),
+		writers: make([]*bufio.Writer, n),
+		sizes:   make([]int64, n),
+	}
+}
+
+func (p *partitions) write(key string, l seqLine) error {
+	h := hash64(key)
+	if p.level > 0 {
+		// Mix the level in, so the lines of a partition being split spread
+		// over all of the new ones.
+		h = fmix64(h ^ uint64(p.level)*0x9e3779b97f4a7c15)
+	}
+
+	i := h % uint64(len(p.files))
+	if p.files[i] == nil {
+		f, err := ioutil.TempFile(p.dir, "sutils-set-")
+		if err != nil {
+			return err
+		}
+
+		p.files[i], p.writers[i] = f, bufio.NewWriter(f)
+	}
+
+	n, err := p.writers[i].WriteString(strconv.Itoa(l.seq) + "\t" + l.text + "\n")
+	p.sizes[i] += int64(n)
+
+	return err
+}
+
+// each calls fn with every line of the i-th partition.
+func (p *partitions) each(i int, fn func(seqLine) error) error {
+	if p.files[i] == nil {
+		return nil
+	}
+
+	if err := p.writers[i].Flush(); err != nil {
+		return err
+	}
+
+	if _, err := p.files[i].Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := NewLineReader(p.files[i])
+	for r.Scan() {
+		tab := strings.IndexByte(r.Text(), '\t')
+		seq, _ := strconv.Atoi(r.Text()[:tab])
+
+		if err := fn(seqLine{seq, r.Text()[tab+1:]}); err != nil {
+			return err
+		}
+	}
+
+	return r.Err()
+}
+
+func (p *partitions) remove() {
+	for _, f := range p.files {
+		if f != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+}
+
+// spilledSetOp finishes a set operation whose second input does not fit in
+// memory. It splits both inputs into partitions, runs the operation on every
+// pair of partitions in turn, and sorts what it produces back into order.
+// t holds the start of the second input, and rb is positioned after it.
+func spilledSetOp(t *hashTable, limit int64, ra, rb *LineReader, to io.Writer) error {
+	o := t.opts
+
+	pa, pb := newPartitions(o.TempDir, 0, sortFanIn), newPartitions(o.TempDir, 0, sortFanIn)
+	defer pa.remove()
+	defer pb.remove()
+
+	spill := func(l seqLine) error {
+		if err := pb.write(o.line(l.text).key, l); err != nil {
+			return &LineError{Op: OpWrite, Line: l.seq, Err: err}
+		}
+
+		return nil
+	}
+
+	for _, l := range t.lines {
+		if err := spill(l); err != nil {
+			return err
+		}
+	}
+
+	for rb.Scan() {
+		if err := spill(seqLine{rb.Line(), rb.Text()}); err != nil {
+			return err
+		}
+	}
+
+	if err := rb.Err(); err != nil {
+		return err
+	}
+
+	linesA := 0
+	for ra.Scan() {
+		linesA = ra.Line()
+
+		if err := pa.write(o.line(ra.Text()).key, seqLine{ra.Line(), ra.Text()}); err != nil {
+			return &LineError{Op: OpWrite, Line: ra.Line(), Err: err}
+		}
+	}
+
+	if err := ra.Err(); err != nil {
+		return err
+	}
+
+	out, err := ioutil.TempFile(o.TempDir, "sutils-set-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		out.Close()
+		os.Remove(out.Name())
+	}()
+
+	ow := bufio.NewWriter(out)
+	sp := &spilledOp{op: t.op, opts: o, limit: limit, base: linesA, emit: func(l seqLine) error {
+		_, err := ow.WriteString(strconv.Itoa(l.seq) + "\t" + l.text + "\n")
+		return err
+	}}
+
+	for i := range pa.files {
+		if err := sp.run(pa, pb, i); err != nil {
+			return err
+		}
+	}
+
+	if err := ow.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	// Sort the output by the numbers it was tagged with, and drop them.
+	sorted, err := ioutil.TempFile(o.TempDir, "sutils-set-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		sorted.Close()
+		os.Remove(sorted.Name())
+	}()
+
+	err = SortLines(NewLineReader(out), sorted, SortOptions{
+		Field: 1, Separator: "\t", Numeric: true, Stable: true,
+		Memory: o.Memory, TempDir: o.TempDir,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := sorted.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	w := &lineWriter{w: to}
+
+	r := NewLineReader(sorted)
+	for r.Scan() {
+		text := r.Text()
+		if err := w.write(text[strings.IndexByte(text, '\t')+1:]); err != nil {
+			return err
+		}
+	}
+
+	return r.Err()
+}
+
+// spilledOp runs a set operation on pairs of partitions, keeping what it
+// holds in memory under limit bytes.
+type spilledOp struct {
+	op    setOp
+	opts  *SortOptions
+	limit int64
+
+	// base is the number of lines of the first input, which the lines a
+	// union adds are ordered after.
+	base int
+	emit func(seqLine) error
+}
+
+// errPartitionFull stops loading a partition that does not fit in memory.
+var errPartitionFull = errors.New("partition full")
+
+// run runs the operation on the i-th partitions of pa and pb. Only joins
+// need the lines of b; the other operations only hold its keys. A partition
+// whose keys do not fit is split further, and the lines of b for a single
+// key of a join are joined in chunks that fit.
+func (s *spilledOp) run(pa, pb *partitions, i int) error {
+	part := newHashTable(s.op, s.opts, s.op != opJoin)
+
+	err := pb.each(i, func(l seqLine) error {
+		if part.size >= s.limit {
+			return errPartitionFull
+		}
+
+		part.add(l.seq, l.text)
+
+		return nil
+	})
+
+	switch {
+	case err == errPartitionFull && len(part.keys) > 1 && pa.level < maxPartitionLevel:
+		return s.split(pa, pb, i)
+	case err == errPartitionFull && s.op == opJoin:
+		return s.joinChunks(pa, pb, i)
+	case err == errPartitionFull:
+		// Past the last level, the keys are held however many there are.
+		err = pb.each(i, func(l seqLine) error {
+			part.add(l.seq, l.text)
+			return nil
+		})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := pa.each(i, func(l seqLine) error { return part.probe(l, s.emit) }); err != nil {
+		return err
+	}
+
+	if s.op != opUnion {
+		return nil
+	}
+
+	return pb.each(i, func(l seqLine) error {
+		if part.inA[s.opts.line(l.text).key] {
+			return nil
+		}
+
+		return s.emit(seqLine{s.base + l.seq, l.text})
+	})
+}
+
+// split splits the i-th partitions of pa and pb into partitions of their
+// own, enough for those of b to fit in memory twice over, and runs the
+// operation on those.
+func (s *spilledOp) split(pa, pb *partitions, i int) error {
+	n := 2 * int((pb.sizes[i]+s.limit-1)/s.limit)
+	if n > sortFanIn {
+		n = sortFanIn
+	}
+
+	subA, subB := newPartitions(s.opts.TempDir, pa.level+1, n), newPartitions(s.opts.TempDir, pb.level+1, n)
+	defer subA.remove()
+	defer subB.remove()
+
+	if err := pa.each(i, func(l seqLine) error { return subA.write(s.opts.line(l.text).key, l) }); err != nil {
+		return err
+	}
+
+	if err := pb.each(i, func(l seqLine) error { return subB.write(s.opts.line(l.text).key, l) }); err != nil {
+		return err
+	}
+
+	for j := range subA.files {
+		if err := s.run(subA, subB, j); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// joinChunks joins the i-th partitions of pa and pb, loading the lines of b
+// a chunk that fits in memory at a time and going through the lines of a for
+// every chunk. The lines joined with a line of a are still in the order of b,
+// since the output is stably sorted by the numbers of the lines of a.
+func (s *spilledOp) joinChunks(pa, pb *partitions, i int) error {
+	part := newHashTable(opJoin, s.opts, false)
+
+	probe := func() error {
+		err := pa.each(i, func(l seqLine) error { return part.probe(l, s.emit) })
+		part = newHashTable(opJoin, s.opts, false)
+
+		return err
+	}
+
+	err := pb.each(i, func(l seqLine) error {
+		part.add(l.seq, l.text)
+		if part.size < s.limit {
+			return nil
+		}
+
+		return probe()
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(part.lines) == 0 {
+		return nil
+	}
+
+	return probe()
+}
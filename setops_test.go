@@ -0,0 +1,193 @@
+package sutils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSortedSetOps(t *testing.T) {
+	a := "apple\nbanana\nbanana\ncherry\r\nfig"
+	b := "banana\ncherry\ndate\nfig\nfig\n"
+
+	tests := []struct {
+		Name     string
+		Op       func(a, b io.Reader, opts SortOptions, to io.Writer) error
+		Expected string
+	}{
+		{"intersect", IntersectSorted, "banana\ncherry\nfig\n"},
+		{"difference", DifferenceSorted, "apple\nbanana\n"},
+		{"union", UnionSorted, "apple\nbanana\nbanana\ncherry\ndate\nfig\nfig\n"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := test.Op(strings.NewReader(a), strings.NewReader(b), SortOptions{}, &buf); err != nil {
+			t.Errorf("%s: errored out: %v", test.Name, err)
+			continue
+		}
+
+		if buf.String() != test.Expected {
+			t.Errorf("%s: mismatch. Expected %q, got %q", test.Name, test.Expected, buf.String())
+		}
+	}
+}
+
+const (
+	joinUsers  = "1 alice\n2 bob\n2 bobby\n4 dave\n"
+	joinOrders = "1 book\n1 pen\n2 lamp\n3 desk\n"
+)
+
+func TestJoinSorted(t *testing.T) {
+	var buf bytes.Buffer
+	if err := JoinSorted(strings.NewReader(joinUsers), strings.NewReader(joinOrders), SortOptions{Field: 1}, &buf); err != nil {
+		t.Fatalf("JoinSorted errored out: %v", err)
+	}
+
+	expected := "1 alice book\n1 alice pen\n2 bob lamp\n2 bobby lamp\n"
+	if buf.String() != expected {
+		t.Errorf("JoinSorted mismatch. Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestHashSetOps(t *testing.T) {
+	a := "Pear\napple\nfig\napple\nkiwi\n"
+	b := "FIG\nplum\nApple\nplum\n"
+
+	tests := []struct {
+		Name     string
+		Op       func(a, b io.Reader, opts SortOptions, to io.Writer) error
+		Expected string
+	}{
+		{"intersect", Intersect, "apple\nfig\napple\n"},
+		{"difference", Difference, "Pear\nkiwi\n"},
+		{"union", Union, "Pear\napple\nfig\napple\nkiwi\nplum\nplum\n"},
+	}
+
+	dir, err := ioutil.TempDir("", "sutils")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range tests {
+		// A memory limit of one byte spills after the first line of b.
+		for _, memory := range []int64{0, 1} {
+			opts := SortOptions{IgnoreCase: true, Memory: memory, TempDir: dir}
+
+			var buf bytes.Buffer
+			if err := test.Op(strings.NewReader(a), strings.NewReader(b), opts, &buf); err != nil {
+				t.Errorf("%s (memory %d): errored out: %v", test.Name, memory, err)
+				continue
+			}
+
+			if buf.String() != test.Expected {
+				t.Errorf("%s (memory %d): mismatch. Expected %q, got %q", test.Name, memory, test.Expected, buf.String())
+			}
+		}
+	}
+
+	left, err := ioutil.ReadDir(dir)
+	if err != nil || len(left) != 0 {
+		t.Errorf("%d temporary files left behind (%v)", len(left), err)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	users := "4 dave\n2 bob\n1 alice\n2 bobby\n"
+	orders := "3 desk\n1 book\n2 lamp\n1 pen\n"
+
+	for _, memory := range []int64{0, 1} {
+		var buf bytes.Buffer
+		if err := Join(strings.NewReader(users), strings.NewReader(orders), SortOptions{Field: 1, Memory: memory}, &buf); err != nil {
+			t.Errorf("memory %d: Join errored out: %v", memory, err)
+			continue
+		}
+
+		expected := "2 bob lamp\n1 alice book\n1 alice pen\n2 bobby lamp\n"
+		if buf.String() != expected {
+			t.Errorf("memory %d: Join mismatch. Expected %q, got %q", memory, expected, buf.String())
+		}
+	}
+}
+
+// keyedLines returns n lines whose first field is one of the given number
+// of keys.
+func keyedLines(seed int64, n, keys int) string {
+	rng := rand.New(rand.NewSource(seed))
+
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "k%d line%d\n", rng.Intn(keys), i)
+	}
+
+	return sb.String()
+}
+
+func TestHashSetOpsLarge(t *testing.T) {
+	a := keyedLines(3, 2000, 3000)
+	b := keyedLines(4, 2000, 3000)
+
+	tests := []struct {
+		Name string
+		Op   func(a, b io.Reader, opts SortOptions, to io.Writer) error
+	}{
+		{"intersect", Intersect},
+		{"difference", Difference},
+		{"union", Union},
+		{"join", Join},
+	}
+
+	for _, test := range tests {
+		opts := SortOptions{Field: 1}
+
+		var inMemory bytes.Buffer
+		if err := test.Op(strings.NewReader(a), strings.NewReader(b), opts, &inMemory); err != nil {
+			t.Fatalf("%s: errored out: %v", test.Name, err)
+		}
+
+		// 1000 bytes spill into partitions that still do not fit, so
+		// they are split again.
+		for _, memory := range []int64{1000, 100} {
+			opts.Memory = memory
+
+			var spilled bytes.Buffer
+			if err := test.Op(strings.NewReader(a), strings.NewReader(b), opts, &spilled); err != nil {
+				t.Fatalf("%s (memory %d): errored out: %v", test.Name, memory, err)
+			}
+
+			if inMemory.Len() == 0 || inMemory.String() != spilled.String() {
+				t.Errorf("%s (memory %d): spilled result differs from the in-memory one", test.Name, memory)
+			}
+		}
+	}
+}
+
+func TestJoinSkewed(t *testing.T) {
+	a := "1 a\n2 b\n1 c\n"
+
+	var sb strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&sb, "1 order%d\n2 other%d\n", i, i)
+	}
+
+	var inMemory, spilled bytes.Buffer
+	if err := Join(strings.NewReader(a), strings.NewReader(sb.String()), SortOptions{Field: 1}, &inMemory); err != nil {
+		t.Fatalf("Join errored out: %v", err)
+	}
+
+	// Every line of b has one of two keys, so the partitions can't be
+	// split enough, and the lines of b are joined in chunks.
+	if err := Join(strings.NewReader(a), strings.NewReader(sb.String()), SortOptions{Field: 1, Memory: 200}, &spilled); err != nil {
+		t.Fatalf("Join errored out: %v", err)
+	}
+
+	if strings.Count(inMemory.String(), "\n") != 1500 || inMemory.String() != spilled.String() {
+		t.Errorf("spilled Join differs from the in-memory one")
+	}
+}
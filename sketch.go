@@ -0,0 +1,144 @@
+package sutils
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// hash64 hashes s with FNV-1a, followed by the finalizer of MurmurHash3 to
+// spread the bits of short strings over the whole hash.
+func hash64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+
+	return fmix64(h.Sum64())
+}
+
+// fmix64 is the finalizer of MurmurHash3, which makes every bit of x affect
+// every bit of the result.
+func fmix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
+
+// CountMinSketch estimates how often strings have been added to it, in a
+// fixed amount of memory. Its estimates are never too low, and with a
+// probability of 1-e^-depth too high by at most e*n/width, n being the
+// number of strings added.
+type CountMinSketch struct {
+	width  uint64
+	counts [][]uint64
+}
+
+// NewCountMinSketch returns a CountMinSketch with depth rows of width
+// counters.
+func NewCountMinSketch(width, depth int) *CountMinSketch {
+	counts := make([][]uint64, depth)
+	for i := range counts {
+		counts[i] = make([]uint64, width)
+	}
+
+	return &CountMinSketch{width: uint64(width), counts: counts}
+}
+
+// cells calls fn with the counter of s in every row.
+func (c *CountMinSketch) cells(s string, fn func(*uint64)) {
+	h := hash64(s)
+	h1, h2 := h&0xffffffff, h>>32
+
+	for i, row := range c.counts {
+		fn(&row[(h1+uint64(i)*h2)%c.width])
+	}
+}
+
+// Add adds s to the sketch.
+func (c *CountMinSketch) Add(s string) {
+	c.cells(s, func(n *uint64) { *n++ })
+}
+
+// Count returns the estimated number of times s has been added.
+func (c *CountMinSketch) Count(s string) uint64 {
+	min := uint64(math.MaxUint64)
+	c.cells(s, func(n *uint64) {
+		if *n < min {
+			min = *n
+		}
+	})
+
+	return min
+}
+
+// HyperLogLog estimates the number of distinct strings added to it, in a
+// fixed amount of memory. With precision p it uses 2^p bytes and has a
+// standard error of about 1.04/sqrt(2^p).
+type HyperLogLog struct {
+	p         uint8
+	registers []uint8
+}
+
+// NewHyperLogLog returns a HyperLogLog with the given precision, which is
+// clamped to between 4 and 18.
+func NewHyperLogLog(precision uint8) *HyperLogLog {
+	if precision < 4 {
+		precision = 4
+	}
+
+	if precision > 18 {
+		precision = 18
+	}
+
+	return &HyperLogLog{p: precision, registers: make([]uint8, 1<<precision)}
+}
+
+// Add adds s to the set.
+func (h *HyperLogLog) Add(s string) {
+	x := hash64(s)
+
+	i := x >> (64 - h.p)
+	rank := uint8(bits.LeadingZeros64(x<<h.p|1<<(h.p-1))) + 1
+
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+// Estimate returns the estimated number of distinct strings added.
+func (h *HyperLogLog) Estimate() uint64 {
+	m := float64(len(h.registers))
+
+	var alpha float64
+	switch len(h.registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha * m * m / sum
+
+	// Small cardinalities are better estimated from the empty registers.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
@@ -0,0 +1,40 @@
+package sutils
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCountMinSketch(t *testing.T) {
+	c := NewCountMinSketch(1024, 4)
+
+	for i := 0; i < 5000; i++ {
+		c.Add(strconv.Itoa(i % 500))
+	}
+
+	for i := 0; i < 500; i++ {
+		if n := c.Count(strconv.Itoa(i)); n < 10 || n > 10+5000*3/1024 {
+			t.Errorf("Count(%d) = %d, expected about 10", i, n)
+		}
+	}
+
+	if n := c.Count("never added"); n > 5000*3/1024 {
+		t.Errorf("Count of a string never added = %d", n)
+	}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	for _, n := range []int{0, 1, 100, 10000, 200000} {
+		h := NewHyperLogLog(14)
+
+		for i := 0; i < n; i++ {
+			h.Add("line " + strconv.Itoa(i))
+			h.Add("line " + strconv.Itoa(i/2))
+		}
+
+		got := float64(h.Estimate())
+		if got < float64(n)*0.97 || got > float64(n)*1.03 {
+			t.Errorf("Estimate() = %v for %d distinct strings", got, n)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package sutils
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// WriterFactory returns the writer the part-th part of a split is written
+// to, counting from 1. The writer is closed once the part is complete.
+type WriterFactory func(part int) (io.WriteCloser, error)
+
+// FileWriterFactory returns a WriterFactory that creates files named by
+// passing the number of the part to fmt.Sprintf with format, for example
+// "access-%03d.log".
+func FileWriterFactory(format string) WriterFactory {
+	return func(part int) (io.WriteCloser, error) {
+		return os.Create(fmt.Sprintf(format, part))
+	}
+}
+
+// SplitLines splits "from" into parts of n lines, the last of which may be
+// shorter, and writes them to the writers parts returns. Like CopyLines, it
+// ends every line with "\n". It returns the number of parts written.
+func SplitLines(from io.Reader, n int, parts WriterFactory) (int, error) {
+	return split(from, parts, func(line string, lines int, size int64) bool {
+		return lines >= n
+	})
+}
+
+// SplitBytes splits "from" into parts of at most n bytes, and writes them to
+// the writers parts returns. Parts end at line boundaries, so a line longer
+// than n bytes makes up a part by itself. It returns the number of parts
+// written.
+func SplitBytes(from io.Reader, n int64, parts WriterFactory) (int, error) {
+	return split(from, parts, func(line string, lines int, size int64) bool {
+		return size+int64(len(line))+1 > n
+	})
+}
+
+// SplitAt splits "from" before every line for which match returns true, the
+// way "csplit" does, and writes the parts to the writers parts returns. The
+// lines before the first match, if there are any, make up the first part.
+// It returns the number of parts written.
+func SplitAt(from io.Reader, match func(string) bool, parts WriterFactory) (int, error) {
+	return split(from, parts, func(line string, lines int, size int64) bool {
+		return match(line)
+	})
+}
+
+// split writes the lines of "from" to parts, starting a new part before
+// every line for which next returns true, given the number of lines and
+// bytes already in the current part. A part always has at least one line.
+func split(from io.Reader, parts WriterFactory, next func(line string, lines int, size int64) bool) (int, error) {
+	r := lineReader(from)
+
+	var (
+		part  io.WriteCloser
+		count int
+		lines int
+		size  int64
+	)
+
+	closePart := func() error {
+		if part == nil {
+			return nil
+		}
+
+		err := part.Close()
+		part = nil
+
+		if err != nil {
+			return &LineError{Op: OpWrite, Line: r.Line() - 1, Err: err}
+		}
+
+		return nil
+	}
+
+	for r.Scan() {
+		if part == nil || lines > 0 && next(r.Text(), lines, size) {
+			if err := closePart(); err != nil {
+				return count, err
+			}
+
+			w, err := parts(count + 1)
+			if err != nil {
+				return count, err
+			}
+
+			part, lines, size = w, 0, 0
+			count++
+		}
+
+		if err := writeLine(part, r); err != nil {
+			part.Close()
+			return count, err
+		}
+
+		lines++
+		size += int64(len(r.Bytes())) + 1
+	}
+
+	if err := r.Err(); err != nil {
+		if part != nil {
+			part.Close()
+		}
+
+		return count, err
+	}
+
+	if part != nil {
+		if err := part.Close(); err != nil {
+			return count, &LineError{Op: OpWrite, Line: r.Line(), Err: err}
+		}
+	}
+
+	return count, nil
+}
+
+// Source is an input to Concat or Interleave.
+type Source struct {
+	// Prefix is written in front of every line of the source, e.g. its name
+	// followed by ": ". It may be empty.
+	Prefix string
+
+	Reader io.Reader
+}
+
+// Concat writes the lines of the sources to "to", one source after the
+// other, each line preceded by the prefix of its source. Like CopyLines, it
+// ends every line with "\n", so a source that does not end in a newline
+// does not run into the next one.
+func Concat(to io.Writer, sources ...Source) error {
+	w := &lineWriter{w: to}
+
+	for i, src := range sources {
+		r := lineReader(src.Reader)
+
+		for r.Scan() {
+			if err := w.write(src.Prefix + r.Text()); err != nil {
+				return err
+			}
+		}
+
+		if err := r.Err(); err != nil {
+			return fmt.Errorf("source %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// Interleave writes the lines of the sources to "to" in turn: the first line
+// of every source, then the second line of every source, and so on, leaving
+// out the sources that have run out of lines. Every line is preceded by the
+// prefix of its source, and ended with "\n".
+func Interleave(to io.Writer, sources ...Source) error {
+	w := &lineWriter{w: to}
+
+	readers := make([]*LineReader, len(sources))
+	for i, src := range sources {
+		readers[i] = lineReader(src.Reader)
+	}
+
+	for left := len(readers); left > 0; {
+		for i, r := range readers {
+			if r == nil {
+				continue
+			}
+
+			if !r.Scan() {
+				if err := r.Err(); err != nil {
+					return fmt.Errorf("source %d: %w", i+1, err)
+				}
+
+				readers[i] = nil
+				left--
+
+				continue
+			}
+
+			if err := w.write(sources[i].Prefix + r.Text()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package sutils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// bufferParts is a WriterFactory writing its parts to memory.
+type bufferParts struct {
+	parts  []*bytes.Buffer
+	closed int
+}
+
+type closingBuffer struct {
+	*bytes.Buffer
+	p *bufferParts
+}
+
+func (c closingBuffer) Close() error {
+	c.p.closed++
+	return nil
+}
+
+func (p *bufferParts) factory(part int) (io.WriteCloser, error) {
+	if part != len(p.parts)+1 {
+		return nil, errors.New("parts out of order")
+	}
+
+	buf := &bytes.Buffer{}
+	p.parts = append(p.parts, buf)
+
+	return closingBuffer{buf, p}, nil
+}
+
+func (p *bufferParts) strings() []string {
+	var s []string
+	for _, buf := range p.parts {
+		s = append(s, buf.String())
+	}
+
+	return s
+}
+
+func TestSplit(t *testing.T) {
+	input := "a\nbb\r\n# section\nccc\ndddd\n# section\ne"
+
+	tests := []struct {
+		Name     string
+		Split    func(io.Reader, WriterFactory) (int, error)
+		Expected []string
+	}{
+		{"lines", func(r io.Reader, f WriterFactory) (int, error) { return SplitLines(r, 2, f) },
+			[]string{"a\nbb\n", "# section\nccc\n", "dddd\n# section\n", "e\n"}},
+		{"bytes", func(r io.Reader, f WriterFactory) (int, error) { return SplitBytes(r, 8, f) },
+			[]string{"a\nbb\n", "# section\n", "ccc\n", "dddd\n", "# section\n", "e\n"}},
+		{"pattern", func(r io.Reader, f WriterFactory) (int, error) {
+			return SplitAt(r, func(line string) bool { return strings.HasPrefix(line, "#") }, f)
+		}, []string{"a\nbb\n", "# section\nccc\ndddd\n", "# section\ne\n"}},
+		{"pattern first line", func(r io.Reader, f WriterFactory) (int, error) {
+			return SplitAt(r, func(line string) bool { return line == "a" }, f)
+		}, []string{"a\nbb\n# section\nccc\ndddd\n# section\ne\n"}},
+	}
+
+	for _, test := range tests {
+		var p bufferParts
+
+		n, err := test.Split(strings.NewReader(input), p.factory)
+		if err != nil {
+			t.Errorf("%s: split errored out: %v", test.Name, err)
+			continue
+		}
+
+		if n != len(test.Expected) || p.closed != n {
+			t.Errorf("%s: %d parts, %d closed; expected %d", test.Name, n, p.closed, len(test.Expected))
+		}
+
+		if got := p.strings(); !reflect.DeepEqual(test.Expected, got) {
+			t.Errorf("%s: parts mismatch. Expected %q, got %q", test.Name, test.Expected, got)
+		}
+	}
+
+	var p bufferParts
+	if n, err := SplitLines(strings.NewReader(""), 10, p.factory); n != 0 || err != nil {
+		t.Errorf("splitting empty input: %d parts, %v", n, err)
+	}
+}
+
+func TestFileWriterFactory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sutils")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	n, err := SplitLines(strings.NewReader("1\n2\n3\n"), 2, FileWriterFactory(filepath.Join(dir, "part-%02d.txt")))
+	if err != nil || n != 2 {
+		t.Fatalf("SplitLines = %d, %v", n, err)
+	}
+
+	for name, expected := range map[string]string{"part-01.txt": "1\n2\n", "part-02.txt": "3\n"} {
+		got, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != expected {
+			t.Errorf("%s: expected %q, got %q (%v)", name, expected, got, err)
+		}
+	}
+}
+
+func TestConcatAndInterleave(t *testing.T) {
+	sources := func() []Source {
+		return []Source{
+			{Prefix: "a: ", Reader: strings.NewReader("1\n2\n3")},
+			{Reader: strings.NewReader("x\r\n")},
+			{Prefix: "c: ", Reader: strings.NewReader("p\nq\n")},
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Concat(&buf, sources()...); err != nil {
+		t.Fatalf("Concat errored out: %v", err)
+	}
+
+	expected := "a: 1\na: 2\na: 3\nx\nc: p\nc: q\n"
+	if buf.String() != expected {
+		t.Errorf("Concat mismatch. Expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := Interleave(&buf, sources()...); err != nil {
+		t.Fatalf("Interleave errored out: %v", err)
+	}
+
+	expected = "a: 1\nx\nc: p\na: 2\nc: q\na: 3\n"
+	if buf.String() != expected {
+		t.Errorf("Interleave mismatch. Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestConcatReadError(t *testing.T) {
+	err := Concat(ioutil.Discard, Source{Reader: strings.NewReader("ok\n")}, Source{Reader: &failingReader{data: strings.NewReader("a\nb\n")}})
+
+	var lerr *LineError
+	if !errors.Is(err, errInjected) || !errors.As(err, &lerr) || !strings.HasPrefix(err.Error(), "source 2: ") {
+		t.Errorf("expected the injected error from source 2, got %v", err)
+	}
+}
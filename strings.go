@@ -1,9 +1,8 @@
 package sutils
 
 import (
-	"bufio"
-	"fmt"
 	"io"
+	"os"
 	"regexp"
 	"strings"
 )
@@ -26,7 +25,8 @@ func IContains(haystack, needle string) bool {
 	return true
 }
 
-// Present checks whether all of its parameters are non-empty.
+// Present checks whether all of its parameters are non-empty. To find out
+// which of several named fields are missing or invalid, use a Validator.
 func Present(reqFields ...string) bool {
 	for _, field := range reqFields {
 		if field == "" {
@@ -61,76 +61,63 @@ func CountCaseSensitive(haystack io.Reader, needle string) (int, error) {
 
 // FindIgnoreCase searches an io.Reader for a given string in a case-insensitive way.
 // It returns the line numbers where it found such strings, or an error if something went wrong.
+//
+// A haystack that is a regular *os.File is mapped into memory and searched
+// there rather than read, where the platform allows it. If the file is
+// truncated during the search, the search fails with ErrTruncated.
 func FindIgnoreCase(haystack io.Reader, needle string) (occurrences []int, err error) {
-	lines := 1
-	r := bufio.NewReader(haystack)
-
-	for {
-		line, _, err := r.ReadLine()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-
-			return nil, fmt.Errorf("reading line: %v", err)
-		}
-
-		if IContains(string(line), needle) {
-			occurrences = append(occurrences, lines)
-		}
-
-		lines++
-	}
-
-	return occurrences, nil
+	return findLines(haystack, func(line string) bool {
+		return IContains(line, needle)
+	})
 }
 
 // FindCaseSensitive searches an io.Reader for a given string in a case sensitive way.
 // It returns the line numbers where it found such strings, or an error if something went wrong.
+//
+// Like FindIgnoreCase, it searches regular files mapped into memory, and
+// fails with ErrTruncated if they are truncated during the search.
 func FindCaseSensitive(haystack io.Reader, needle string) (occurrences []int, err error) {
-	lines := 1
-	r := bufio.NewReader(haystack)
-
-	for {
-		line, _, err := r.ReadLine()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-
-			return nil, fmt.Errorf("reading line: %v", err)
-		}
-
-		if strings.Contains(string(line), needle) {
-			occurrences = append(occurrences, lines)
+	if f, ok := haystack.(*os.File); ok {
+		if found, ok, err := findMappedIndex(f, needle); ok {
+			return found, err
 		}
-
-		lines++
 	}
 
-	return occurrences, nil
+	return findLines(haystack, func(line string) bool {
+		return strings.Contains(line, needle)
+	})
 }
 
 // FindStartsWith searches an io.Reader for all lines that start with a given string in a case sensitive way.
 // It returns the line numbers where it found such strings, or an error if something went wrong.
+//
+// Like FindIgnoreCase, it searches regular files mapped into memory, and
+// fails with ErrTruncated if they are truncated during the search.
 func FindStartsWith(haystack io.Reader, needle string) (occurrences []int, err error) {
-	lines := 1
-	r := bufio.NewReader(haystack)
-
-	for {
-		line, _, err := r.ReadLine()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
+	return findLines(haystack, func(line string) bool {
+		return strings.HasPrefix(line, needle)
+	})
+}
 
+// findLines returns the numbers of the lines in haystack for which match
+// returns true. Regular files are mapped into memory where possible instead
+// of being read.
+func findLines(haystack io.Reader, match func(string) bool) (occurrences []int, err error) {
+	if f, ok := haystack.(*os.File); ok {
+		if found, ok, err := findMapped(f, match); ok {
+			return found, err
 		}
+	}
 
-		if strings.HasPrefix(string(line), needle) {
-			occurrences = append(occurrences, lines)
+	r := lineReader(haystack)
+	for r.Scan() {
+		if match(r.Text()) {
+			occurrences = append(occurrences, r.Line())
 		}
+	}
 
-		lines++
+	if err := r.Err(); err != nil {
+		return nil, err
 	}
 
 	return occurrences, nil
@@ -149,6 +136,9 @@ func TrimNL(s string) string {
 // if the second argument is found in the first one, false otherwise.
 //
 // FindWith's return is indexed from 1 instead of 0.
+//
+// Like FindIgnoreCase, it searches regular files mapped into memory, and
+// fails with ErrTruncated if they are truncated during the search.
 func FindWith(find func(string, string) bool, haystack io.Reader, needles []string) ([]int, error) {
 	occurrences := make([]int, 0)
 
@@ -156,98 +146,101 @@ func FindWith(find func(string, string) bool, haystack io.Reader, needles []stri
 		return occurrences, nil
 	}
 
-	scanner := bufio.NewScanner(haystack)
-	buf := make([]byte, 0, 10*1024*1024)
-	scanner.Buffer(buf, 10*1024*1024)
-
-	lnum := 0
-	for scanner.Scan() {
-		lnum++
-
-		line := scanner.Text()
-
+	found, err := findLines(haystack, func(line string) bool {
 		for _, needle := range needles {
 			if find(line, needle) {
-				occurrences = append(occurrences, lnum)
-				break
+				return true
 			}
 		}
-	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("reading file: %v", err)
+		return false
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return occurrences, nil
+	return append(occurrences, found...), nil
 }
 
 // CopyLines copies the lines specified in the "lines" from the
 // io.Reader "from" to the io.Writer "to".
 //
-// Replaces carriage returns with normal returns
+// Replaces carriage returns with normal returns. The input is transcoded
+// to UTF-8; use CopyLinesKeepEncoding to write in the input's encoding.
 func CopyLines(from io.Reader, lines []int, to io.Writer) error {
 	if len(lines) == 0 {
 		return nil
 	}
 
-	lineMap := make(map[int]bool)
+	return copyLines(lineReader(from), lines, to)
+}
 
-	for _, l := range lines {
-		lineMap[l] = true
+// CopyLinesKeepEncoding works like CopyLines, but writes the lines in the
+// encoding detected in "from", including its byte order mark if it had one.
+func CopyLinesKeepEncoding(from io.Reader, lines []int, to io.Writer) error {
+	if len(lines) == 0 {
+		return nil
 	}
 
-	scanner := bufio.NewScanner(from)
-	lnum := 0
+	from, enc := DecodeReader(from)
+	if bom := enc.BOM(); bom != nil {
+		if _, err := to.Write(bom); err != nil {
+			return &LineError{Op: OpWrite, Line: 1, Err: err}
+		}
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
+	return copyLines(NewLineReader(from), lines, NewEncodingWriter(to, enc))
+}
 
-		lnum++
+func copyLines(r *LineReader, lines []int, to io.Writer) error {
+	lineMap := make(map[int]bool)
 
-		if _, ok := lineMap[lnum]; !ok {
+	for _, l := range lines {
+		lineMap[l] = true
+	}
+
+	for r.Scan() {
+		if _, ok := lineMap[r.Line()]; !ok {
 			continue
 		}
 
-		to.Write([]byte(line))
-		to.Write([]byte(fmt.Sprintln()))
-	}
-
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("reading from file: %v", err)
+		if err := writeLine(to, r); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return r.Err()
 }
 
 // CopyWithoutLines copies from the reader "from" to the writer
 // "to" without the line numbers specified by "lines".
 func CopyWithoutLines(from io.Reader, lines []int, to io.Writer) error {
+	r := lineReader(from)
 	lineMap := make(map[int]bool)
 
 	for _, l := range lines {
 		lineMap[l] = true
 	}
 
-	scanner := bufio.NewScanner(from)
-	buf := make([]byte, 0, 10*1024*1024)
-	scanner.Buffer(buf, 10*1024*1024)
-
-	lnum := 0
-	for scanner.Scan() {
-		lnum++
-
-		line := scanner.Text()
-
-		if _, ok := lineMap[lnum]; ok {
+	for r.Scan() {
+		if _, ok := lineMap[r.Line()]; ok {
 			continue
 		}
 
-		to.Write([]byte(line))
-		to.Write([]byte("\n"))
+		if err := writeLine(to, r); err != nil {
+			return err
+		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("reading from file: %v", err)
+	return r.Err()
+}
+
+// writeLine writes the current line of r to w, terminated by "\n".
+func writeLine(w io.Writer, r *LineReader) error {
+	line := append(r.Bytes(), '\n')
+
+	if _, err := w.Write(line); err != nil {
+		return &LineError{Op: OpWrite, Line: r.Line(), Offset: r.Offset(), Err: err}
 	}
 
 	return nil
@@ -0,0 +1,393 @@
+package sutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Fields are the named values parsed from a structured log line.
+type Fields map[string]string
+
+// LineParser parses a line into fields.
+type LineParser func(line string) (Fields, error)
+
+// ErrNotParsed is returned by the parsers for lines not in their format.
+var ErrNotParsed = errors.New("line not in the expected format")
+
+// ParseJSONLine parses a line of JSON Lines. Nested objects and arrays are
+// flattened into dotted keys, such as "user.name" and "tags.0". Numbers are
+// kept as written, and null values become empty strings. Lines that are not
+// a single JSON object make it return ErrNotParsed.
+func ParseJSONLine(line string) (Fields, error) {
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+
+	var v map[string]interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotParsed, err)
+	}
+
+	if v == nil {
+		return nil, fmt.Errorf("%w: not a JSON object", ErrNotParsed)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: data after the JSON object", ErrNotParsed)
+	}
+
+	fields := make(Fields)
+	flatten(fields, "", v)
+
+	return fields, nil
+}
+
+func flatten(fields Fields, key string, v interface{}) {
+	join := func(k string) string {
+		if key == "" {
+			return k
+		}
+
+		return key + "." + k
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			flatten(fields, join(k), val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			flatten(fields, join(strconv.Itoa(i)), val)
+		}
+	case nil:
+		fields[key] = ""
+	default:
+		fields[key] = fmt.Sprint(v)
+	}
+}
+
+// ParseLogfmt parses a logfmt line: space separated key=value pairs, where
+// values may be double quoted. A key without a value is set to "true".
+func ParseLogfmt(line string) (Fields, error) {
+	fields := make(Fields)
+
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+
+		key := line[start:i]
+		if key == "" {
+			return nil, fmt.Errorf("%w: missing key at %d", ErrNotParsed, start)
+		}
+
+		if i == len(line) || line[i] != '=' {
+			fields[key] = "true"
+			continue
+		}
+
+		i++
+
+		if i < len(line) && line[i] == '"' {
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+
+			if end >= len(line) {
+				return nil, fmt.Errorf("%w: unterminated quote at %d", ErrNotParsed, i)
+			}
+
+			value, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrNotParsed, err)
+			}
+
+			fields[key] = value
+			i = end + 1
+
+			continue
+		}
+
+		start = i
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+
+		fields[key] = line[start:i]
+	}
+
+	return fields, nil
+}
+
+var combinedLog = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\S+)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+
+// ParseCombinedLog parses a line in the Apache/Nginx common or combined log
+// format. The fields are remote_addr, ident, user, time, request, method,
+// path, protocol, status and bytes, and for the combined format also referer
+// and user_agent.
+func ParseCombinedLog(line string) (Fields, error) {
+	idx := combinedLog.FindStringSubmatchIndex(line)
+	if idx == nil {
+		return nil, ErrNotParsed
+	}
+
+	m := make([]string, len(idx)/2)
+	for i := range m {
+		if idx[2*i] >= 0 {
+			m[i] = line[idx[2*i]:idx[2*i+1]]
+		}
+	}
+
+	fields := Fields{
+		"remote_addr": m[1],
+		"ident":       m[2],
+		"user":        m[3],
+		"time":        m[4],
+		"request":     m[5],
+		"status":      m[6],
+		"bytes":       m[7],
+	}
+
+	if parts := strings.Fields(m[5]); len(parts) == 3 {
+		fields["method"], fields["path"], fields["protocol"] = parts[0], parts[1], parts[2]
+	}
+
+	// The referer and user agent are only there in the combined format.
+	if idx[16] >= 0 {
+		fields["referer"], fields["user_agent"] = m[8], m[9]
+	}
+
+	return fields, nil
+}
+
+var (
+	syslog5424 = regexp.MustCompile(`^<(\d{1,3})>(\d{1,2}) (\S+) (\S+) (\S+) (\S+) (\S+) (-|(?:\[(?:[^\]"\\]|\\.|"(?:[^"\\]|\\.)*")*\])+)(?: (.*))?$`)
+	syslog3164 = regexp.MustCompile(`^(?:<(\d{1,3})>)?([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) (\S+) ([^:\[\s]+)(?:\[([^\]]*)\])?: ?(.*)$`)
+)
+
+// ParseSyslog parses a syslog line in the RFC 5424 or the RFC 3164 (BSD)
+// format. The fields are timestamp, hostname, app and message, plus pid if
+// present. Lines with a priority also get priority, facility and severity;
+// RFC 5424 lines also get version, msgid and structured_data. Nil values
+// ("-") are left out.
+func ParseSyslog(line string) (Fields, error) {
+	fields := make(Fields)
+
+	set := func(key, value string) {
+		if value != "" && value != "-" {
+			fields[key] = value
+		}
+	}
+
+	var pri string
+
+	if m := syslog5424.FindStringSubmatch(line); m != nil {
+		pri = m[1]
+		set("version", m[2])
+		set("timestamp", m[3])
+		set("hostname", m[4])
+		set("app", m[5])
+		set("pid", m[6])
+		set("msgid", m[7])
+		set("structured_data", m[8])
+		set("message", strings.TrimPrefix(m[9], "\ufeff"))
+	} else if m := syslog3164.FindStringSubmatch(line); m != nil {
+		pri = m[1]
+		set("timestamp", m[2])
+		set("hostname", m[3])
+		set("app", m[4])
+		set("pid", m[5])
+		set("message", m[6])
+	} else {
+		return nil, ErrNotParsed
+	}
+
+	if pri != "" {
+		p, _ := strconv.Atoi(pri)
+		fields["priority"] = pri
+		fields["facility"] = strconv.Itoa(p / 8)
+		fields["severity"] = strconv.Itoa(p % 8)
+	}
+
+	return fields, nil
+}
+
+// FieldQuery is a condition on the value of a field, such as "level=error"
+// or "status>=500".
+type FieldQuery struct {
+	Field string
+
+	// Op is one of "=", "!=", "<", "<=", ">", ">=" and "~", the last of
+	// which means "contains".
+	Op string
+
+	Value string
+}
+
+// queryOps are the operators of a FieldQuery.
+var queryOps = []string{"!=", "<=", ">=", "=", "<", ">", "~"}
+
+// ParseFieldQuery parses a query of the form field, operator, value, e.g.
+// "status>=500".
+func ParseFieldQuery(query string) (FieldQuery, error) {
+	at, op := -1, ""
+
+	for _, o := range queryOps {
+		if i := strings.Index(query, o); i > 0 && (at < 0 || i < at) {
+			at, op = i, o
+		}
+	}
+
+	if at < 0 {
+		return FieldQuery{}, fmt.Errorf("parsing field query %q: no operator", query)
+	}
+
+	// "a>=b" has both ">" and "=" at the same position as ">=".
+	for _, o := range queryOps {
+		if strings.HasPrefix(query[at:], o) && len(o) > len(op) {
+			op = o
+		}
+	}
+
+	return FieldQuery{Field: query[:at], Op: op, Value: query[at+len(op):]}, nil
+}
+
+// Match reports whether the fields satisfy the query. Values that both parse
+// as numbers are compared as numbers, others as strings. A missing field
+// only satisfies "!=".
+func (q FieldQuery) Match(fields Fields) bool {
+	v, ok := fields[q.Field]
+	if !ok {
+		return q.Op == "!="
+	}
+
+	if q.Op == "~" {
+		return strings.Contains(v, q.Value)
+	}
+
+	cmp := strings.Compare(v, q.Value)
+
+	a, errA := strconv.ParseFloat(v, 64)
+	b, errB := strconv.ParseFloat(q.Value, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		default:
+			cmp = 0
+		}
+	}
+
+	switch q.Op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+
+	return false
+}
+
+func parseQueries(queries []string) ([]FieldQuery, error) {
+	parsed := make([]FieldQuery, len(queries))
+
+	for i, q := range queries {
+		var err error
+		if parsed[i], err = ParseFieldQuery(q); err != nil {
+			return nil, err
+		}
+	}
+
+	return parsed, nil
+}
+
+// FindFields returns the numbers of the lines of haystack that parse into
+// fields that match all of the queries. Lines that cannot be parsed do not
+// match.
+func FindFields(haystack io.Reader, parse LineParser, queries ...string) ([]int, error) {
+	parsed, err := parseQueries(queries)
+	if err != nil {
+		return nil, err
+	}
+
+	return findLines(haystack, func(line string) bool {
+		fields, err := parse(line)
+		if err != nil {
+			return false
+		}
+
+		for _, q := range parsed {
+			if !q.Match(fields) {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+// ProjectFields parses every line of "from" and writes the requested fields
+// of it to "to" as a logfmt line, in the order they were requested. Fields
+// a line does not have are left out; lines that cannot be parsed, or have
+// none of the fields, are skipped.
+func ProjectFields(from io.Reader, parse LineParser, fields []string, to io.Writer) error {
+	r := lineReader(from)
+
+	for r.Scan() {
+		parsed, err := parse(r.Text())
+		if err != nil {
+			continue
+		}
+
+		var pairs []string
+		for _, f := range fields {
+			if v, ok := parsed[f]; ok {
+				pairs = append(pairs, f+"="+logfmtValue(v))
+			}
+		}
+
+		if len(pairs) == 0 {
+			continue
+		}
+
+		if _, err := io.WriteString(to, strings.Join(pairs, " ")+"\n"); err != nil {
+			return &LineError{Op: OpWrite, Line: r.Line(), Offset: r.Offset(), Err: err}
+		}
+	}
+
+	return r.Err()
+}
+
+// logfmtValue quotes v if it would not survive being written bare.
+func logfmtValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\"=\\") || strings.IndexFunc(v, func(r rune) bool { return r < ' ' }) >= 0 {
+		return strconv.Quote(v)
+	}
+
+	return v
+}
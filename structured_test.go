@@ -0,0 +1,152 @@
+package sutils
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineParsers(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Parse    LineParser
+		Line     string
+		Expected Fields
+	}{
+		{
+			"json", ParseJSONLine,
+			`{"level":"error","status":503,"user":{"name":"joe","tags":["a","b"]},"extra":null,"ok":false}`,
+			Fields{"level": "error", "status": "503", "user.name": "joe", "user.tags.0": "a", "user.tags.1": "b", "extra": "", "ok": "false"},
+		},
+		{
+			"logfmt", ParseLogfmt,
+			`level=error msg="disk \"sda\" full" status=500 debug  empty=`,
+			Fields{"level": "error", "msg": `disk "sda" full`, "status": "500", "debug": "true", "empty": ""},
+		},
+		{
+			"common", ParseCombinedLog,
+			`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			Fields{"remote_addr": "127.0.0.1", "ident": "-", "user": "frank", "time": "10/Oct/2000:13:55:36 -0700",
+				"request": "GET /apache_pb.gif HTTP/1.0", "method": "GET", "path": "/apache_pb.gif", "protocol": "HTTP/1.0",
+				"status": "200", "bytes": "2326"},
+		},
+		{
+			"combined", ParseCombinedLog,
+			`10.0.0.2 - - [10/Oct/2000:13:55:36 -0700] "POST /login HTTP/1.1" 503 - "" "curl/7.1"`,
+			Fields{"remote_addr": "10.0.0.2", "ident": "-", "user": "-", "time": "10/Oct/2000:13:55:36 -0700",
+				"request": "POST /login HTTP/1.1", "method": "POST", "path": "/login", "protocol": "HTTP/1.1",
+				"status": "503", "bytes": "-", "referer": "", "user_agent": "curl/7.1"},
+		},
+		{
+			"rfc5424", ParseSyslog,
+			`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3"] An application event`,
+			Fields{"priority": "165", "facility": "20", "severity": "5", "version": "1", "timestamp": "2003-10-11T22:14:15.003Z",
+				"hostname": "mymachine.example.com", "app": "evntslog", "msgid": "ID47",
+				"structured_data": `[exampleSDID@32473 iut="3"]`, "message": "An application event"},
+		},
+		{
+			"rfc3164", ParseSyslog,
+			`Oct 11 22:14:15 mymachine sshd[4123]: Accepted publickey for root`,
+			Fields{"timestamp": "Oct 11 22:14:15", "hostname": "mymachine", "app": "sshd", "pid": "4123", "message": "Accepted publickey for root"},
+		},
+	}
+
+	for _, test := range tests {
+		fields, err := test.Parse(test.Line)
+		if err != nil {
+			t.Errorf("%s: parsing %q errored out: %v", test.Name, test.Line, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(test.Expected, fields) {
+			t.Errorf("%s: parsing %q mismatch.\nExpected %v\ngot      %v", test.Name, test.Line, test.Expected, fields)
+		}
+	}
+
+	for _, parse := range []LineParser{ParseJSONLine, ParseCombinedLog, ParseSyslog} {
+		if _, err := parse("just some text"); !errors.Is(err, ErrNotParsed) {
+			t.Errorf("expected ErrNotParsed for unstructured text, got %v", err)
+		}
+	}
+
+	for _, line := range []string{"null", `{"level":"error"} trailing junk`, `{"a":1}{"b":2}`, `[1,2]`} {
+		if _, err := ParseJSONLine(line); !errors.Is(err, ErrNotParsed) {
+			t.Errorf("expected ErrNotParsed for %q, got %v", line, err)
+		}
+	}
+
+	if fields, err := ParseJSONLine(`{"a":1}  `); err != nil || fields["a"] != "1" {
+		t.Errorf("expected trailing spaces to be ignored, got %v, %v", fields, err)
+	}
+}
+
+func TestFieldQuery(t *testing.T) {
+	fields := Fields{"level": "error", "status": "503", "path": "/api/users"}
+
+	tests := []struct {
+		Query    string
+		Expected bool
+	}{
+		{"level=error", true},
+		{"level!=error", false},
+		{"status>=500", true},
+		{"status>503", false},
+		{"status<1000", true},
+		{"status<=503", true},
+		{"path~/api", true},
+		{"missing=x", false},
+		{"missing!=x", true},
+		{"level>debug", true},
+	}
+
+	for _, test := range tests {
+		q, err := ParseFieldQuery(test.Query)
+		if err != nil {
+			t.Errorf("ParseFieldQuery(%q) errored out: %v", test.Query, err)
+			continue
+		}
+
+		if q.Match(fields) != test.Expected {
+			t.Errorf("%q (%+v) should match: %v", test.Query, q, test.Expected)
+		}
+	}
+
+	if _, err := ParseFieldQuery("nooperator"); err == nil {
+		t.Errorf("expected an error for a query without operator")
+	}
+}
+
+func TestFindAndProjectFields(t *testing.T) {
+	input := `{"level":"info","status":200,"msg":"ok"}
+not json at all
+{"level":"error","status":503,"msg":"upstream down"}
+{"level":"error","status":404,"msg":"not \"found\""}
+`
+
+	found, err := FindFields(strings.NewReader(input), ParseJSONLine, "level=error", "status>=500")
+	if err != nil {
+		t.Fatalf("FindFields errored out: %v", err)
+	}
+
+	if !reflect.DeepEqual([]int{3}, found) {
+		t.Errorf("FindFields mismatch. Expected [3], got %v", found)
+	}
+
+	var out bytes.Buffer
+	if err := ProjectFields(strings.NewReader(input), ParseJSONLine, []string{"status", "msg"}, &out); err != nil {
+		t.Fatalf("ProjectFields errored out: %v", err)
+	}
+
+	expected := "status=200 msg=ok\nstatus=503 msg=\"upstream down\"\nstatus=404 msg=\"not \\\"found\\\"\"\n"
+	if msg, ok := expect(expected, out.String()); !ok {
+		t.Error(msg)
+	}
+
+	// What ProjectFields writes, ParseLogfmt reads back.
+	last := strings.Split(strings.TrimSpace(out.String()), "\n")[2]
+	if fields, _ := ParseLogfmt(last); fields["msg"] != `not "found"` {
+		t.Errorf("ProjectFields output did not round trip: %q", fields["msg"])
+	}
+}
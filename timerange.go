@@ -0,0 +1,228 @@
+package sutils
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// probeLines is how many lines the binary search of CopyBetween reads at
+// most, looking for a timestamp, before giving up on a probe.
+const probeLines = 256
+
+var (
+	// isoTimestamp matches RFC 3339 and the common variants of it that use a
+	// space instead of the "T", no seconds, a comma before the fraction or
+	// no zone.
+	isoTimestamp = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})[T ](\d{2}:\d{2}(?::\d{2})?)(?:[.,](\d{1,9}))?(Z|[+-]\d{2}:?\d{2})?`)
+
+	syslogTimestamp = regexp.MustCompile(`^[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}`)
+	apacheTimestamp = regexp.MustCompile(`\[(\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4})\]`)
+	epochTimestamp  = regexp.MustCompile(`^(\d{10}(?:\.\d{1,9})?|\d{13})\b`)
+
+	// timestampLead is what may come before a timestamp at the start of a
+	// line: a syslog priority and version, or an opening bracket.
+	timestampLead = regexp.MustCompile(`^(?:<\d{1,3}>\d{0,2} ?|\[)`)
+)
+
+// DetectTimestamp looks for a timestamp in a log line and returns it. It
+// recognises RFC 3339 (and ISO 8601 with a space instead of the "T"), syslog
+// ("Jan  2 15:04:05"), Apache ("[02/Jan/2006:15:04:05 -0700]") and Unix
+// epoch seconds or milliseconds. All but the Apache timestamp have to be at
+// the start of the line, after an optional syslog priority or bracket.
+//
+// Timestamps without a zone are taken to be UTC, and syslog timestamps,
+// which have no year, to be in the current year.
+func DetectTimestamp(line string) (time.Time, bool) {
+	if t, ok := leadingTimestamp(line); ok {
+		return t, true
+	}
+
+	return apacheTime(line, false)
+}
+
+// apacheTime parses the first Apache timestamp in line, which has to be at
+// its start if anchored is true.
+func apacheTime(line string, anchored bool) (time.Time, bool) {
+	m := apacheTimestamp.FindStringSubmatchIndex(line)
+	if m == nil || anchored && m[0] != 0 {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse("02/Jan/2006:15:04:05 -0700", line[m[2]:m[3]])
+
+	return t, err == nil
+}
+
+// leadingTimestamp is DetectTimestamp for timestamps at the start of line.
+func leadingTimestamp(line string) (time.Time, bool) {
+	if t, ok := apacheTime(line, true); ok {
+		return t, true
+	}
+
+	if lead := timestampLead.FindString(line); lead != "" {
+		line = line[len(lead):]
+	}
+
+	if m := isoTimestamp.FindStringSubmatch(line); m != nil {
+		clock := m[2]
+		if len(clock) == len("15:04") {
+			clock += ":00"
+		}
+
+		value, layout := m[1]+"T"+clock, "2006-01-02T15:04:05"
+
+		if m[3] != "" {
+			value += "." + m[3]
+			layout += ".999999999"
+		}
+
+		switch {
+		case m[4] == "Z":
+			value += "Z"
+			layout += "Z07:00"
+		case len(m[4]) == 6:
+			value += m[4]
+			layout += "-07:00"
+		case m[4] != "":
+			value += m[4]
+			layout += "-0700"
+		}
+
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+
+	if m := syslogTimestamp.FindString(line); m != "" {
+		if t, err := time.Parse(time.Stamp, m); err == nil {
+			return t.AddDate(time.Now().Year(), 0, 0), true
+		}
+	}
+
+	if m := epochTimestamp.FindString(line); m != "" {
+		if len(m) == 13 {
+			ms, _ := strconv.ParseInt(m, 10, 64)
+			return time.Unix(0, ms*int64(time.Millisecond)).UTC(), true
+		}
+
+		secs, err := strconv.ParseFloat(m, 64)
+		if err == nil {
+			whole := int64(secs)
+			return time.Unix(whole, int64((secs-float64(whole))*1e9)).UTC(), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// timeWindow tracks the timestamp that applies to each line of a log.
+// Lines without a timestamp, like the lines of a stack trace, take the
+// timestamp of the line before them.
+type timeWindow struct {
+	from, to time.Time
+	last     time.Time
+	known    bool
+}
+
+// at returns where the line falls relative to the window: -1 before it, 0
+// within it and 1 after it. Lines before the first timestamp are before it.
+func (w *timeWindow) at(line string) int {
+	if t, ok := DetectTimestamp(line); ok {
+		w.last, w.known = t, true
+	}
+
+	switch {
+	case !w.known || w.last.Before(w.from):
+		return -1
+	case !w.last.Before(w.to):
+		return 1
+	}
+
+	return 0
+}
+
+// FindBetween returns the numbers of the lines of haystack whose timestamp
+// is at or after "from" and before "to". Lines without a timestamp of
+// their own take the timestamp of the line before them. The input does not
+// need to be sorted; see CopyBetween for sorted input.
+func FindBetween(haystack io.Reader, from, to time.Time) ([]int, error) {
+	w := &timeWindow{from: from, to: to}
+
+	return findLines(haystack, func(line string) bool {
+		return w.at(line) == 0
+	})
+}
+
+// CopyBetween copies the lines of the first size bytes of r whose timestamp
+// is at or after "from" and before "to" to w, like CopyLines does. The
+// input must be sorted by time: CopyBetween binary searches it for the first
+// line to copy and stops at the first line past the window.
+func CopyBetween(r io.ReaderAt, size int64, from, to time.Time, w io.Writer) error {
+	start, err := seekTime(r, size, from)
+	if err != nil {
+		return err
+	}
+
+	window := &timeWindow{from: from, to: to}
+
+	lr := NewLineReader(io.NewSectionReader(r, start, size-start))
+	for lr.Scan() {
+		switch window.at(lr.Text()) {
+		case -1:
+			continue
+		case 1:
+			return nil
+		}
+
+		if err := writeLine(w, lr); err != nil {
+			return err
+		}
+	}
+
+	return lr.Err()
+}
+
+// seekTime returns the offset of a line in sorted input such that every line
+// with a timestamp before it is before t. Reading forward from there finds
+// the first line at or after t.
+func seekTime(r io.ReaderAt, size int64, t time.Time) (int64, error) {
+	lo, hi := int64(0), size
+
+	for hi-lo > 64*1024 {
+		mid := lo + (hi-lo)/2
+
+		start, err := nextLineStart(r, mid, size)
+		if err != nil {
+			return 0, err
+		}
+
+		at, ts, ok, err := firstTimestamp(r, start, hi)
+		if err != nil {
+			return 0, err
+		}
+
+		if ok && ts.Before(t) {
+			lo = at
+		} else {
+			hi = mid
+		}
+	}
+
+	return lo, nil
+}
+
+// firstTimestamp returns the offset and timestamp of the first line starting
+// between start and end that has one, looking at no more than probeLines.
+func firstTimestamp(r io.ReaderAt, start, end int64) (int64, time.Time, bool, error) {
+	lr := NewLineReader(io.NewSectionReader(r, start, end-start))
+
+	for lr.Scan() && lr.Line() <= probeLines {
+		if ts, ok := DetectTimestamp(lr.Text()); ok {
+			return start + lr.Offset(), ts, true, nil
+		}
+	}
+
+	return 0, time.Time{}, false, lr.Err()
+}
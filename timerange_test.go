@@ -0,0 +1,154 @@
+package sutils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectTimestamp(t *testing.T) {
+	year := time.Now().Year()
+
+	tests := []struct {
+		Line string
+		Want time.Time
+		OK   bool
+	}{
+		{"2017-06-01T12:00:00Z starting", time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC), true},
+		{"2017-06-01T12:00:00.250+02:00 starting", time.Date(2017, 6, 1, 10, 0, 0, 250e6, time.UTC), true},
+		{"2017-06-01 12:00:00,5 INFO starting", time.Date(2017, 6, 1, 12, 0, 0, 500e6, time.UTC), true},
+		{"2017-06-01 12:00 INFO starting", time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC), true},
+		{"[2017-06-01 12:00:00] starting", time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC), true},
+		{"<165>1 2017-06-01T12:00:00.003Z host app - - - hi", time.Date(2017, 6, 1, 12, 0, 0, 3e6, time.UTC), true},
+		{"Jun  1 12:00:00 host sshd[42]: hi", time.Date(year, 6, 1, 12, 0, 0, 0, time.UTC), true},
+		{"<34>Jun 11 12:00:00 host su: hi", time.Date(year, 6, 11, 12, 0, 0, 0, time.UTC), true},
+		{`127.0.0.1 - - [01/Jun/2017:12:00:00 +0200] "GET / HTTP/1.1" 200 5`, time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC), true},
+		{"1496318400000 starting", time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC), true},
+		{"1496318400.5 starting", time.Date(2017, 6, 1, 12, 0, 0, 500e6, time.UTC), true},
+		{"\tat com.example.Foo.bar(Foo.java:42)", time.Time{}, false},
+		{"14963184000000 too long", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		got, ok := DetectTimestamp(test.Line)
+		if ok != test.OK || !got.Equal(test.Want) {
+			t.Errorf("DetectTimestamp(%q) = %v, %v; expected %v, %v", test.Line, got, ok, test.Want, test.OK)
+		}
+	}
+}
+
+func TestFindBetween(t *testing.T) {
+	at := func(sec int) time.Time {
+		return time.Date(2017, 6, 1, 12, 0, sec, 0, time.UTC)
+	}
+
+	tests := []struct {
+		Name     string
+		Input    string
+		From, To time.Time
+		Lines    []int
+	}{
+		{"stack trace", javaLog, at(1), at(2), []int{2, 3, 4, 5, 6, 7}},
+		{"to is exclusive", javaLog, at(0), at(1), []int{1}},
+		{"all", javaLog, at(0), at(3), []int{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"none", javaLog, at(3), at(4), nil},
+		{"unsorted", "2017-06-01 12:00:02 c\n2017-06-01 12:00:00 a\n2017-06-01 12:00:01 b", at(1), at(3), []int{1, 3}},
+		{"leading lines", "no time\n2017-06-01 12:00:01 b", at(0), at(3), []int{2}},
+	}
+
+	for _, test := range tests {
+		lines, err := FindBetween(strings.NewReader(test.Input), test.From, test.To)
+		if err != nil {
+			t.Errorf("%s: FindBetween errored out: %v", test.Name, err)
+			continue
+		}
+
+		if len(lines) != 0 || len(test.Lines) != 0 {
+			if !reflect.DeepEqual(test.Lines, lines) {
+				t.Errorf("%s: lines mismatch. Expected %v, got %v", test.Name, test.Lines, lines)
+			}
+		}
+	}
+}
+
+// countingReaderAt counts the bytes read through it.
+type countingReaderAt struct {
+	r io.ReaderAt
+	n int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.n += int64(n)
+
+	return n, err
+}
+
+// sortedLog returns a log with a line every second, and every tenth line
+// followed by an indented continuation line.
+func sortedLog(n int) string {
+	var sb strings.Builder
+
+	start := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "%s event %d\n", start.Add(time.Duration(i)*time.Second).Format(time.RFC3339), i)
+
+		if i%10 == 0 {
+			fmt.Fprintf(&sb, "\tdetail of event %d\n", i)
+		}
+	}
+
+	return sb.String()
+}
+
+func TestCopyBetween(t *testing.T) {
+	log := sortedLog(50000)
+	start := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	windows := []struct {
+		From, To int
+	}{
+		{30000, 30480},
+		{0, 5},
+		{49990, 60000},
+		{-10, 3},
+		{60000, 70000},
+		{12345, 12345},
+	}
+
+	for _, w := range windows {
+		from := start.Add(time.Duration(w.From) * time.Second)
+		to := start.Add(time.Duration(w.To) * time.Second)
+
+		lines, err := FindBetween(strings.NewReader(log), from, to)
+		if err != nil {
+			t.Fatalf("FindBetween errored out: %v", err)
+		}
+
+		var expected bytes.Buffer
+		if err := CopyLines(strings.NewReader(log), lines, &expected); err != nil {
+			t.Fatalf("CopyLines errored out: %v", err)
+		}
+
+		r := &countingReaderAt{r: strings.NewReader(log)}
+
+		var got bytes.Buffer
+		if err := CopyBetween(r, int64(len(log)), from, to, &got); err != nil {
+			t.Errorf("%d-%d: CopyBetween errored out: %v", w.From, w.To, err)
+			continue
+		}
+
+		if got.String() != expected.String() {
+			t.Errorf("%d-%d: CopyBetween copied %d bytes, expected %d", w.From, w.To, got.Len(), expected.Len())
+		}
+
+		if r.n > int64(len(log))/4 {
+			t.Errorf("%d-%d: CopyBetween read %d bytes of %d", w.From, w.To, r.n, len(log))
+		}
+	}
+}
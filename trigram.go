@@ -0,0 +1,613 @@
+package sutils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// DefaultBlockLines is the number of lines per block in a TrigramIndex when
+// no other value is given.
+const DefaultBlockLines = 1000
+
+// trigramIndexVersion is the version of the on-disk format. Indexes written
+// in any other version are rejected by ReadTrigramIndex.
+const trigramIndexVersion = 1
+
+var trigramIndexMagic = [4]byte{'S', 'T', 'R', 'I'}
+
+// headSize is the number of leading bytes checksummed to notice a file that
+// was replaced rather than appended to.
+const headSize = 4096
+
+// maxIndexPath is the longest path ReadTrigramIndex accepts.
+const maxIndexPath = 4096
+
+// ErrIndexVersion is returned when reading an index written in an unknown
+// format version.
+var ErrIndexVersion = errors.New("unsupported index version")
+
+// TrigramIndex records which three-byte sequences occur in which blocks of
+// lines of a set of files. It is used to skip the files and blocks that
+// cannot contain a needle before searching them for real.
+//
+// Files are indexed as UTF-8.
+type TrigramIndex struct {
+	// BlockLines is the number of lines in a block.
+	BlockLines int
+
+	// Files are the indexed files, in the order they were added.
+	Files []*IndexedFile
+}
+
+// IndexedFile is a file in a TrigramIndex.
+type IndexedFile struct {
+	Path string
+	Size int64
+
+	// Blocks are the consecutive blocks of lines the file is split into.
+	Blocks []IndexBlock
+
+	headLen int64
+	headSum uint32
+
+	// postings lists the blocks each trigram occurs in, in increasing order.
+	postings map[uint32][]uint32
+}
+
+// IndexBlock is a run of lines in an indexed file.
+type IndexBlock struct {
+	// Offset is the byte offset of the first line of the block.
+	Offset int64
+
+	// Line is the number of the first line of the block, counting from 1.
+	Line int
+
+	// Lines is the number of lines in the block.
+	Lines int
+}
+
+// BuildTrigramIndex indexes the given files in blocks of blockLines lines.
+// A blockLines of zero or less means DefaultBlockLines.
+func BuildTrigramIndex(blockLines int, paths ...string) (*TrigramIndex, error) {
+	if blockLines <= 0 {
+		blockLines = DefaultBlockLines
+	}
+
+	idx := &TrigramIndex{BlockLines: blockLines}
+
+	for _, path := range paths {
+		if err := idx.Add(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return idx, nil
+}
+
+// Add indexes the file at path and adds it to the index.
+func (idx *TrigramIndex) Add(path string) error {
+	file := &IndexedFile{Path: path, postings: make(map[uint32][]uint32)}
+
+	if err := idx.index(file, 0); err != nil {
+		return err
+	}
+
+	idx.Files = append(idx.Files, file)
+
+	return nil
+}
+
+// Update brings the index up to date with the files on disk. Files that were
+// appended to only have their new lines indexed; files that shrank or whose
+// beginning changed, even if their size did not, are indexed again from
+// scratch.
+func (idx *TrigramIndex) Update() error {
+	for _, file := range idx.Files {
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			return fmt.Errorf("updating index of %s: %v", file.Path, err)
+		}
+
+		appended, err := file.sameHead()
+		if err != nil {
+			return fmt.Errorf("updating index of %s: %v", file.Path, err)
+		}
+
+		if appended && info.Size() == file.Size {
+			continue
+		}
+
+		if !appended || info.Size() < file.Size || len(file.Blocks) == 0 {
+			file.Blocks = nil
+			file.postings = make(map[uint32][]uint32)
+
+			if err := idx.index(file, 0); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		// The last block may be incomplete, or end in a line that has grown
+		// since, so it is indexed again along with the new lines.
+		last := uint32(len(file.Blocks) - 1)
+		for t, blocks := range file.postings {
+			if n := len(blocks); n > 0 && blocks[n-1] == last {
+				if n == 1 {
+					delete(file.postings, t)
+				} else {
+					file.postings[t] = blocks[:n-1]
+				}
+			}
+		}
+
+		if err := idx.index(file, int(last)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sameHead reports whether the beginning of the file on disk is what it was
+// when the file was indexed.
+func (file *IndexedFile) sameHead() (bool, error) {
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	sum, n, err := checksumHead(f, file.headLen)
+	if err != nil {
+		return false, err
+	}
+
+	return n == file.headLen && sum == file.headSum, nil
+}
+
+func checksumHead(r io.Reader, size int64) (uint32, int64, error) {
+	h := crc32.NewIEEE()
+
+	n, err := io.Copy(h, io.LimitReader(r, size))
+	if err != nil {
+		return 0, n, err
+	}
+
+	return h.Sum32(), n, nil
+}
+
+// index (re)builds the blocks of file starting from block number "from".
+func (idx *TrigramIndex) index(file *IndexedFile, from int) error {
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return fmt.Errorf("indexing %s: %v", file.Path, err)
+	}
+	defer f.Close()
+
+	var offset int64
+	line := 1
+
+	if from < len(file.Blocks) {
+		offset, line = file.Blocks[from].Offset, file.Blocks[from].Line
+		file.Blocks = file.Blocks[:from]
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("indexing %s: %v", file.Path, err)
+	}
+
+	r := NewLineReader(f)
+
+	var block *IndexBlock
+	for r.Scan() {
+		if block == nil || block.Lines == idx.BlockLines {
+			file.Blocks = append(file.Blocks, IndexBlock{Offset: offset + r.Offset(), Line: line + r.Line() - 1})
+			block = &file.Blocks[len(file.Blocks)-1]
+		}
+
+		block.Lines++
+
+		id := uint32(len(file.Blocks) - 1)
+		eachTrigram(r.Bytes(), func(t uint32) {
+			blocks := file.postings[t]
+			if n := len(blocks); n == 0 || blocks[n-1] != id {
+				file.postings[t] = append(blocks, id)
+			}
+		})
+	}
+
+	if err := r.Err(); err != nil {
+		return fmt.Errorf("indexing %s: %v", file.Path, err)
+	}
+
+	file.Size = offset + r.next
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("indexing %s: %v", file.Path, err)
+	}
+
+	head := file.Size
+	if head > headSize {
+		head = headSize
+	}
+
+	file.headSum, file.headLen, err = checksumHead(f, head)
+	if err != nil {
+		return fmt.Errorf("indexing %s: %v", file.Path, err)
+	}
+
+	return nil
+}
+
+// foldASCII lowercases an ASCII letter, leaving any other byte alone.
+func foldASCII(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+
+	return b
+}
+
+// eachTrigram calls fn with every trigram of s that a case-insensitive match
+// can rely on: those made of ASCII bytes, lowercased. Trigrams with a 'k' or
+// an 's' are left out, since those letters also match the non-ASCII Kelvin
+// and long s signs when ignoring case.
+func eachTrigram(s []byte, fn func(uint32)) {
+	for i := 0; i+3 <= len(s); i++ {
+		var t uint32
+		usable := true
+
+		for _, b := range s[i : i+3] {
+			b = foldASCII(b)
+			if b >= 0x80 || b == 'k' || b == 's' {
+				usable = false
+				break
+			}
+
+			t = t<<8 | uint32(b)
+		}
+
+		if usable {
+			fn(t)
+		}
+	}
+}
+
+// Candidates returns, for every indexed file, the blocks that may contain
+// needle when ignoring case. Files without any candidate blocks are left
+// out. Needles that contain regular expression syntax, which IContains
+// would interpret, are not narrowed down at all.
+func (idx *TrigramIndex) Candidates(needle string) map[string][]IndexBlock {
+	var trigrams []uint32
+
+	if regexp.QuoteMeta(needle) == needle {
+		seen := make(map[uint32]bool)
+		eachTrigram([]byte(needle), func(t uint32) {
+			if !seen[t] {
+				seen[t] = true
+				trigrams = append(trigrams, t)
+			}
+		})
+	}
+
+	candidates := make(map[string][]IndexBlock)
+
+	for _, file := range idx.Files {
+		var blocks []IndexBlock
+
+		if len(trigrams) == 0 {
+			blocks = append(blocks, file.Blocks...)
+		} else {
+			for _, id := range file.intersect(trigrams) {
+				blocks = append(blocks, file.Blocks[id])
+			}
+		}
+
+		if len(blocks) > 0 {
+			candidates[file.Path] = blocks
+		}
+	}
+
+	return candidates
+}
+
+// intersect returns the blocks that contain every one of the trigrams.
+func (file *IndexedFile) intersect(trigrams []uint32) []uint32 {
+	result := file.postings[trigrams[0]]
+
+	for _, t := range trigrams[1:] {
+		if len(result) == 0 {
+			break
+		}
+
+		other := file.postings[t]
+		var both []uint32
+
+		for i, j := 0, 0; i < len(result) && j < len(other); {
+			switch {
+			case result[i] < other[j]:
+				i++
+			case result[i] > other[j]:
+				j++
+			default:
+				both = append(both, result[i])
+				i++
+				j++
+			}
+		}
+
+		result = both
+	}
+
+	return result
+}
+
+// FindIgnoreCase searches the indexed files for needle in a case-insensitive
+// way, like the package level FindIgnoreCase does, but only reads the blocks
+// the index cannot rule out. It returns the matching line numbers by path;
+// files without matches are left out.
+//
+// The index should be brought up to date with Update first if the files may
+// have changed.
+func (idx *TrigramIndex) FindIgnoreCase(needle string) (map[string][]int, error) {
+	results := make(map[string][]int)
+
+	for path, blocks := range idx.Candidates(needle) {
+		found, err := findInBlocks(path, blocks, needle)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(found) > 0 {
+			results[path] = found
+		}
+	}
+
+	return results, nil
+}
+
+func findInBlocks(path string, blocks []IndexBlock, needle string) ([]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var found []int
+
+	for _, block := range blocks {
+		r := NewLineReader(io.NewSectionReader(f, block.Offset, 1<<62))
+
+		for r.Scan() && r.Line() <= block.Lines {
+			if IContains(r.Text(), needle) {
+				found = append(found, block.Line+r.Line()-1)
+			}
+		}
+
+		if err := r.Err(); err != nil {
+			return nil, fmt.Errorf("searching %s: %v", path, err)
+		}
+	}
+
+	return found, nil
+}
+
+// Save writes the index to the file at path.
+func (idx *TrigramIndex) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := idx.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// LoadTrigramIndex reads an index saved with Save.
+func LoadTrigramIndex(path string) (*TrigramIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadTrigramIndex(f)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w *bufio.Writer
+	n int64
+}
+
+func (c *countingWriter) uvarint(v uint64) error {
+	var buf [binary.MaxVarintLen64]byte
+
+	n := binary.PutUvarint(buf[:], v)
+	_, err := c.Write(buf[:n])
+
+	return err
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+
+	return n, err
+}
+
+// WriteTo writes the index to w in a versioned binary format that can be
+// read back with ReadTrigramIndex.
+func (idx *TrigramIndex) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	if err := idx.write(cw); err != nil {
+		return cw.n, err
+	}
+
+	return cw.n, cw.w.Flush()
+}
+
+func (idx *TrigramIndex) write(w *countingWriter) error {
+	if _, err := w.Write(trigramIndexMagic[:]); err != nil {
+		return err
+	}
+
+	header := []uint64{trigramIndexVersion, uint64(idx.BlockLines), uint64(len(idx.Files))}
+	for _, v := range header {
+		if err := w.uvarint(v); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range idx.Files {
+		if err := w.uvarint(uint64(len(file.Path))); err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, file.Path); err != nil {
+			return err
+		}
+
+		fields := []uint64{uint64(file.Size), uint64(file.headLen), uint64(file.headSum), uint64(len(file.Blocks))}
+		for _, b := range file.Blocks {
+			fields = append(fields, uint64(b.Offset), uint64(b.Line), uint64(b.Lines))
+		}
+
+		trigrams := make([]uint32, 0, len(file.postings))
+		for t := range file.postings {
+			trigrams = append(trigrams, t)
+		}
+		sort.Slice(trigrams, func(i, j int) bool { return trigrams[i] < trigrams[j] })
+
+		fields = append(fields, uint64(len(trigrams)))
+		for _, t := range trigrams {
+			blocks := file.postings[t]
+			fields = append(fields, uint64(t), uint64(len(blocks)))
+
+			// Block numbers are increasing, so only the gaps are stored.
+			var prev uint32
+			for _, b := range blocks {
+				fields = append(fields, uint64(b-prev))
+				prev = b
+			}
+		}
+
+		for _, v := range fields {
+			if err := w.uvarint(v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReadTrigramIndex reads an index written by WriteTo. It returns
+// ErrIndexVersion if the index was written in another format version.
+func ReadTrigramIndex(r io.Reader) (*TrigramIndex, error) {
+	br := bufio.NewReader(r)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return nil, fmt.Errorf("reading index: %v", err)
+	}
+
+	if magic != trigramIndexMagic {
+		return nil, errors.New("reading index: not a trigram index")
+	}
+
+	var err error
+	next := func() uint64 {
+		if err != nil {
+			return 0
+		}
+
+		var v uint64
+		v, err = binary.ReadUvarint(br)
+
+		return v
+	}
+
+	if version := next(); err == nil && version != trigramIndexVersion {
+		return nil, fmt.Errorf("reading index: %w (%d)", ErrIndexVersion, version)
+	}
+
+	// inRange checks a count read from the index against max, so a corrupt
+	// index makes for an error rather than an attempt to allocate the
+	// impossible.
+	inRange := func(what string, v, min, max uint64) uint64 {
+		if err == nil && (v < min || v > max) {
+			err = fmt.Errorf("%s %d out of range", what, v)
+		}
+
+		return v
+	}
+
+	idx := &TrigramIndex{BlockLines: int(inRange("lines per block", next(), 1, math.MaxInt32))}
+
+	files := next()
+	for i := uint64(0); i < files && err == nil; i++ {
+		pathLen := inRange("path length", next(), 0, maxIndexPath)
+		if err != nil {
+			break
+		}
+
+		path := make([]byte, pathLen)
+		_, err = io.ReadFull(br, path)
+
+		file := &IndexedFile{
+			Path:     string(path),
+			Size:     int64(inRange("file size", next(), 0, math.MaxInt64)),
+			headLen:  int64(next()),
+			headSum:  uint32(next()),
+			postings: make(map[uint32][]uint32),
+		}
+
+		// Every block but the last ends in a newline, so a file has at
+		// most a block per byte, and one more.
+		blocks := inRange("block count", next(), 0, uint64(file.Size)+1)
+		for j := uint64(0); j < blocks && err == nil; j++ {
+			file.Blocks = append(file.Blocks, IndexBlock{Offset: int64(next()), Line: int(next()), Lines: int(next())})
+		}
+
+		trigrams := inRange("trigram count", next(), 0, 1<<24)
+		for j := uint64(0); j < trigrams && err == nil; j++ {
+			t, n := uint32(inRange("trigram", next(), 0, 1<<24-1)), inRange("posting count", next(), 0, blocks)
+
+			// Block numbers must increase and name blocks of the file, or
+			// Candidates would index past its blocks.
+			var prev uint64
+			for k := uint64(0); k < n && err == nil; k++ {
+				gap := next()
+				if k > 0 {
+					inRange("posting gap", gap, 1, blocks)
+				}
+
+				prev = inRange("posting", prev+gap, 0, blocks-1)
+				file.postings[t] = append(file.postings[t], uint32(prev))
+			}
+		}
+
+		idx.Files = append(idx.Files, file)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading index: %v", err)
+	}
+
+	return idx, nil
+}
@@ -0,0 +1,182 @@
+package sutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, files map[string]string) (string, []string) {
+	dir, err := ioutil.TempDir("", "sutils")
+	if err != nil {
+		t.Fatalf("failed creating temp dir: %v", err)
+	}
+
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed writing %s: %v", path, err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	return dir, paths
+}
+
+// expectedMatches runs the package level FindIgnoreCase on every file.
+func expectedMatches(t *testing.T, paths []string, needle string) map[string][]int {
+	expected := make(map[string][]int)
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("failed opening %s: %v", path, err)
+		}
+
+		found, err := FindIgnoreCase(f, needle)
+		f.Close()
+
+		if err != nil {
+			t.Fatalf("FindIgnoreCase(%s) errored out: %v", path, err)
+		}
+
+		if len(found) > 0 {
+			expected[path] = found
+		}
+	}
+
+	return expected
+}
+
+func TestTrigramIndex(t *testing.T) {
+	var big strings.Builder
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&big, "line %d of the big file\n", i)
+	}
+	big.WriteString("the Needle is near the end\nno newline at the end")
+
+	dir, paths := writeTestFiles(t, map[string]string{
+		"a.log":   "first line\nsecond line\nERROR: disk full\n",
+		"b.log":   "nothing to see\nhere\n",
+		"big.log": big.String(),
+		"empty":   "",
+	})
+	defer os.RemoveAll(dir)
+
+	idx, err := BuildTrigramIndex(7, paths...)
+	if err != nil {
+		t.Fatalf("BuildTrigramIndex errored out: %v", err)
+	}
+
+	needles := []string{"error", "NEEDLE", "line 5", "line 9", "no", "the end", "a", "", "missing", "disk.*", "KELVIN", "gone"}
+
+	check := func(idx *TrigramIndex) {
+		for _, needle := range needles {
+			found, err := idx.FindIgnoreCase(needle)
+			if err != nil {
+				t.Errorf("FindIgnoreCase(%q) errored out: %v", needle, err)
+			}
+
+			if expected := expectedMatches(t, paths, needle); !reflect.DeepEqual(expected, found) {
+				t.Errorf("FindIgnoreCase(%q) mismatch. Expected %v, got %v", needle, expected, found)
+			}
+		}
+	}
+
+	check(idx)
+
+	if c := idx.Candidates("disk full"); len(c) != 1 || len(c[filepath.Join(dir, "a.log")]) != 1 {
+		t.Errorf("Candidates(\"disk full\") should only return the one block of a.log, got %v", c)
+	}
+
+	var buf bytes.Buffer
+	if _, err := idx.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo errored out: %v", err)
+	}
+
+	loaded, err := ReadTrigramIndex(&buf)
+	if err != nil {
+		t.Fatalf("ReadTrigramIndex errored out: %v", err)
+	}
+
+	if !reflect.DeepEqual(idx, loaded) {
+		t.Errorf("index changed after writing and reading it back")
+	}
+
+	// Append to one file, replace another and replace a third with content
+	// of the same size.
+	f, err := os.OpenFile(filepath.Join(dir, "big.log"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed opening big.log: %v", err)
+	}
+	f.WriteString(" but now there is\nanother needle\n")
+	f.Close()
+
+	ioutil.WriteFile(filepath.Join(dir, "b.log"), []byte("replaced\nwith an Error\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "a.log"), []byte("first line\nsecond line\nERROR: disk gone\n"), 0644)
+
+	blocks := len(loaded.Files[0].Blocks)
+	if err := loaded.Update(); err != nil {
+		t.Fatalf("Update errored out: %v", err)
+	}
+
+	check(loaded)
+
+	fresh, _ := BuildTrigramIndex(7, paths...)
+	if !reflect.DeepEqual(fresh, loaded) {
+		t.Errorf("updated index differs from a freshly built one (had %d blocks)", blocks)
+	}
+}
+
+func TestReadTrigramIndexVersion(t *testing.T) {
+	data := append([]byte("STRI"), 99, 1, 0)
+
+	if _, err := ReadTrigramIndex(bytes.NewReader(data)); !errors.Is(err, ErrIndexVersion) {
+		t.Errorf("expected ErrIndexVersion, got %v", err)
+	}
+
+	if _, err := ReadTrigramIndex(strings.NewReader("nope")); err == nil {
+		t.Errorf("expected an error for data that is not an index")
+	}
+}
+
+func TestReadTrigramIndexCorrupt(t *testing.T) {
+	index := func(fields ...uint64) []byte {
+		data := []byte("STRI")
+		for _, v := range fields {
+			var buf [binary.MaxVarintLen64]byte
+			data = append(data, buf[:binary.PutUvarint(buf[:], v)]...)
+		}
+
+		return data
+	}
+
+	tests := []struct {
+		Name string
+		Data []byte
+	}{
+		{"no lines per block", index(1, 0, 0)},
+		{"huge path", index(1, 7, 1, 1<<62)},
+		{"huge block count", index(1, 7, 1, 1, 'a', 10, 0, 0, 1<<40)},
+		{"huge trigram count", index(1, 7, 1, 1, 'a', 10, 0, 0, 0, 1<<40)},
+		{"huge posting count", index(1, 7, 1, 1, 'a', 10, 0, 0, 1, 0, 0, 3, 1, 'a', 1<<40)},
+		{"posting past the blocks", index(1, 7, 1, 1, 'a', 10, 0, 0, 2, 0, 1, 1, 5, 2, 1, 1, 0, 1, 5)},
+		{"postings not increasing", index(1, 7, 1, 1, 'a', 10, 0, 0, 2, 0, 1, 1, 5, 2, 1, 1, 0, 2, 1, 0)},
+		{"truncated", index(1, 7, 1, 1, 'a', 10, 0, 0, 1)},
+	}
+
+	for _, test := range tests {
+		if _, err := ReadTrigramIndex(bytes.NewReader(test.Data)); err == nil {
+			t.Errorf("%s: expected an error", test.Name)
+		}
+	}
+}
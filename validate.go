@@ -0,0 +1,209 @@
+package sutils
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rule is a check on the value of a field, for a Validator.
+type Rule struct {
+	// Name names the rule in a FieldError, e.g. "max".
+	Name string
+
+	// Check returns an error saying what is wrong with value, such as
+	// "must be at most 63 characters long", or nil if nothing is.
+	Check func(value string) error
+}
+
+// FieldError is a rule a field failed.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every rule the fields given to a Validator failed.
+type ValidationError struct {
+	Fields []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Validator checks named fields against rules and collects the failures. Its
+// zero value is ready to use:
+//
+//	var v Validator
+//	v.Check("name", name, Required, MaxLength(63))
+//	v.Check("port", port, Port)
+//	if err := v.Err(); err != nil {
+//		...
+//	}
+//
+// Like Present, Required fails for empty values. The other rules are only
+// checked for values that are not empty, so optional fields can have rules
+// too.
+type Validator struct {
+	errs []FieldError
+}
+
+// Check checks the value of the field called name against rules, and records
+// every rule it fails.
+func (v *Validator) Check(name, value string, rules ...Rule) *Validator {
+	for _, rule := range rules {
+		if value == "" && rule.Name != Required.Name {
+			continue
+		}
+
+		if err := rule.Check(value); err != nil {
+			v.Fail(name, rule.Name, err.Error())
+
+			if value == "" {
+				break
+			}
+		}
+	}
+
+	return v
+}
+
+// Fail records that the field called name failed a rule, for checks that
+// don't fit into a Rule.
+func (v *Validator) Fail(name, rule, message string) *Validator {
+	v.errs = append(v.errs, FieldError{Field: name, Rule: rule, Message: message})
+	return v
+}
+
+// Err returns a *ValidationError listing the failures in the order they
+// were found, or nil if there were none.
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Fields: append([]FieldError(nil), v.errs...)}
+}
+
+// Required fails for empty values.
+var Required = Rule{Name: "required", Check: func(value string) error {
+	if value == "" {
+		return errors.New("must be set")
+	}
+
+	return nil
+}}
+
+// MinLength fails for values shorter than n characters.
+func MinLength(n int) Rule {
+	return Rule{Name: "min", Check: func(value string) error {
+		if utf8.RuneCountInString(value) < n {
+			return fmt.Errorf("must be at least %d characters long", n)
+		}
+
+		return nil
+	}}
+}
+
+// MaxLength fails for values longer than n characters.
+func MaxLength(n int) Rule {
+	return Rule{Name: "max", Check: func(value string) error {
+		if utf8.RuneCountInString(value) > n {
+			return fmt.Errorf("must be at most %d characters long", n)
+		}
+
+		return nil
+	}}
+}
+
+// Matches fails for values re does not match.
+func Matches(re *regexp.Regexp) Rule {
+	return Rule{Name: "regex", Check: func(value string) error {
+		if !re.MatchString(value) {
+			return fmt.Errorf("must match %s", re)
+		}
+
+		return nil
+	}}
+}
+
+// OneOf fails for values other than the given ones.
+func OneOf(values ...string) Rule {
+	return Rule{Name: "oneof", Check: func(value string) error {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+	}}
+}
+
+// Email fails for values that are not a bare email address, like
+// "bob@example.com".
+var Email = Rule{Name: "email", Check: func(value string) error {
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value || !strings.Contains(value[strings.LastIndex(value, "@"):], ".") {
+		return errors.New("must be an email address")
+	}
+
+	return nil
+}}
+
+// URL fails for values that are not absolute URLs with a host, like
+// "https://example.com/path".
+var URL = Rule{Name: "url", Check: func(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("must be an absolute URL")
+	}
+
+	return nil
+}}
+
+var hostnameLabel = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?$`)
+
+// Hostname fails for values that are not host names as described in RFC
+// 1123: labels of letters, digits and hyphens, not starting or ending with a
+// hyphen, of at most 63 characters, separated by dots, at most 253
+// characters in all.
+var Hostname = Rule{Name: "hostname", Check: func(value string) error {
+	name := strings.TrimSuffix(value, ".")
+	if name == "" || len(name) > 253 {
+		return errors.New("must be a host name")
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) > 63 || !hostnameLabel.MatchString(label) {
+			return errors.New("must be a host name")
+		}
+	}
+
+	return nil
+}}
+
+// Port fails for values that are not port numbers, from 1 to 65535.
+var Port = Rule{Name: "port", Check: func(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > 65535 {
+		return errors.New("must be a port number between 1 and 65535")
+	}
+
+	return nil
+}}
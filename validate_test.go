@@ -0,0 +1,96 @@
+package sutils
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule  Rule
+		value string
+		valid bool
+	}{
+		{Required, "x", true},
+		{Required, "", false},
+		{MinLength(3), "abc", true},
+		{MinLength(3), "ab", false},
+		{MaxLength(3), "árv", true},
+		{MaxLength(3), "abcd", false},
+		{Matches(regexp.MustCompile(`^[a-z]+$`)), "abc", true},
+		{Matches(regexp.MustCompile(`^[a-z]+$`)), "ab1", false},
+		{OneOf("mysql", "postgres"), "postgres", true},
+		{OneOf("mysql", "postgres"), "oracle", false},
+		{Email, "bob@example.com", true},
+		{Email, "Bob <bob@example.com>", false},
+		{Email, "bob@localhost", false},
+		{Email, "bob", false},
+		{URL, "https://example.com/path?q=1", true},
+		{URL, "example.com/path", false},
+		{URL, "/path", false},
+		{Hostname, "db-1.example.com", true},
+		{Hostname, "example.com.", true},
+		{Hostname, "-db.example.com", false},
+		{Hostname, "db_1.example.com", false},
+		{Hostname, "a..b", false},
+		{Hostname, strings.Repeat("a", 64) + ".com", false},
+		{Port, "8080", true},
+		{Port, "0", false},
+		{Port, "65536", false},
+		{Port, "http", false},
+	}
+
+	for _, test := range tests {
+		err := test.rule.Check(test.value)
+		if (err == nil) != test.valid {
+			t.Errorf("%s(%q) mismatch. Expected valid: %v, got error %v", test.rule.Name, test.value, test.valid, err)
+		}
+	}
+}
+
+func TestValidator(t *testing.T) {
+	var v Validator
+
+	if err := v.Err(); err != nil {
+		t.Fatalf("expected no error before any checks, got %v", err)
+	}
+
+	v.Check("name", "", Required, MaxLength(63)).
+		Check("host", "db_1", Required, Hostname).
+		Check("port", "99999", Port, MinLength(6)).
+		Check("email", "", Email).
+		Check("url", "https://example.com", Required, URL)
+
+	err := v.Err()
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a *ValidationError, got %T", err)
+	}
+
+	expected := []FieldError{
+		{Field: "name", Rule: "required", Message: "must be set"},
+		{Field: "host", Rule: "hostname", Message: "must be a host name"},
+		{Field: "port", Rule: "port", Message: "must be a port number between 1 and 65535"},
+		{Field: "port", Rule: "min", Message: "must be at least 6 characters long"},
+	}
+
+	if !reflect.DeepEqual(expected, verr.Fields) {
+		t.Errorf("mismatch. Expected %v, got %v", expected, verr.Fields)
+	}
+
+	if !strings.HasPrefix(err.Error(), "validation failed: name: must be set; host: ") {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+
+	out, _ := json.Marshal(verr)
+	if !strings.HasPrefix(string(out), `{"errors":[{"field":"name","rule":"required","message":"must be set"}`) {
+		t.Errorf("unexpected JSON %s", out)
+	}
+}
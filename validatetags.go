@@ -0,0 +1,399 @@
+package sutils
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	// tagRulesMu guards tagRules and the cache of the rules of struct
+	// fields, which RegisterRule empties.
+	tagRulesMu sync.RWMutex
+	tagRules   = map[string]func(param string) (Rule, error){
+		"regex":    regexRule,
+		"oneof":    func(param string) (Rule, error) { return OneOf(strings.Fields(param)...), nil },
+		"email":    fixedRule(Email),
+		"url":      fixedRule(URL),
+		"hostname": fixedRule(Hostname),
+		"port":     fixedRule(Port),
+	}
+
+	fieldRulesCache = make(map[fieldKey]*fieldRules)
+	fieldRulesGen   int
+)
+
+func fixedRule(rule Rule) func(string) (Rule, error) {
+	return func(string) (Rule, error) { return rule, nil }
+}
+
+func regexRule(param string) (Rule, error) {
+	re, err := regexp.Compile(param)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	return Matches(re), nil
+}
+
+// RegisterRule makes the rule newRule returns usable in struct tags as name,
+// or "name=param". newRule is called with param, or "" if there is none, and
+// returns an error if param is not valid for the rule. The rules required,
+// min and max can't be replaced.
+func RegisterRule(name string, newRule func(param string) (Rule, error)) {
+	tagRulesMu.Lock()
+	defer tagRulesMu.Unlock()
+
+	tagRules[name] = newRule
+
+	// The cached rules may use the rule this one replaces.
+	fieldRulesCache = make(map[fieldKey]*fieldRules)
+	fieldRulesGen++
+}
+
+// fieldRules are the rules in the tag of a struct field.
+type fieldRules struct {
+	required bool
+	min, max string
+	rules    []Rule
+}
+
+// fieldKey is a field of a struct type.
+type fieldKey struct {
+	typ   reflect.Type
+	index int
+}
+
+// structFieldRules returns the rules in the tag of field i of the struct
+// type t, parsing the tag only the first time.
+func structFieldRules(t reflect.Type, i int) (*fieldRules, error) {
+	key := fieldKey{t, i}
+
+	tagRulesMu.RLock()
+	fr, ok := fieldRulesCache[key]
+	gen := fieldRulesGen
+	tagRulesMu.RUnlock()
+
+	if ok {
+		return fr, nil
+	}
+
+	fr, err := parseTag(t.Field(i).Tag.Get("sutils"))
+	if err != nil {
+		return nil, err
+	}
+
+	tagRulesMu.Lock()
+	if gen == fieldRulesGen {
+		fieldRulesCache[key] = fr
+	}
+	tagRulesMu.Unlock()
+
+	return fr, nil
+}
+
+// parseTag parses a tag like "required,max=63". Everything after "regex="
+// is the regular expression, commas included.
+func parseTag(tag string) (*fieldRules, error) {
+	fr := &fieldRules{}
+
+	for tag != "" {
+		var item string
+		if strings.HasPrefix(tag, "regex=") {
+			item, tag = tag, ""
+		} else if i := strings.IndexByte(tag, ','); i >= 0 {
+			item, tag = tag[:i], tag[i+1:]
+		} else {
+			item, tag = tag, ""
+		}
+
+		name, param := item, ""
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			name, param = item[:i], item[i+1:]
+		}
+
+		switch name {
+		case "":
+		case "required":
+			fr.required = true
+		case "min", "max":
+			if _, err := strconv.ParseFloat(param, 64); err != nil {
+				return nil, fmt.Errorf("rule %s: %q is not a number", name, param)
+			}
+
+			if name == "min" {
+				fr.min = param
+			} else {
+				fr.max = param
+			}
+		default:
+			tagRulesMu.RLock()
+			newRule, ok := tagRules[name]
+			tagRulesMu.RUnlock()
+
+			if !ok {
+				return nil, fmt.Errorf("unknown rule %q", name)
+			}
+
+			rule, err := newRule(param)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", name, err)
+			}
+
+			fr.rules = append(fr.rules, rule)
+		}
+	}
+
+	return fr, nil
+}
+
+// ValidateStruct validates the fields of the struct s, or the struct s
+// points to, against the rules in their "sutils" tags:
+//
+//	type DB struct {
+//		Name  string   `sutils:"required,max=63,regex=^[a-z_]+$"`
+//		Host  string   `sutils:"required,hostname"`
+//		Port  int      `sutils:"min=1,max=65535"`
+//		Users []User   `sutils:"min=1"`
+//		Roles []string `sutils:"oneof=admin reader"`
+//	}
+//
+// The rules are required, min and max, those of the Validator (regex, oneof,
+// email, url, hostname and port) and the ones added with RegisterRule. min
+// and max limit the length of strings, the values of numbers and the number
+// of elements of slices, arrays and maps. The other rules check strings,
+// numbers and booleans as text, and the elements of slices, arrays and maps.
+// As with a Validator, only required is checked for empty (zero) values.
+//
+// ValidateStruct walks into nested structs, pointers and the elements of
+// slices, arrays and maps, so their fields are validated too. Failures are
+// reported in a *ValidationError with the paths of the fields, like
+// "db.users[2].name", made of their names in their "json" tags, or their
+// names in lower case. Fields tagged `sutils:"-"` are skipped. Tags with
+// mistakes, like unknown rules, make it return an error of their own.
+func ValidateStruct(s interface{}) error {
+	val := reflect.ValueOf(s)
+	for val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+
+	if val.Kind() != reflect.Struct {
+		return fmt.Errorf("ValidateStruct needs a struct, got %T", s)
+	}
+
+	// The walk starts at s rather than val, so that values pointing back
+	// to the struct s points to are noticed as cycles.
+	w := &structWalk{Validator: &Validator{}, walking: make(map[visit]bool)}
+	if err := w.walk("", reflect.ValueOf(s), &fieldRules{}); err != nil {
+		return err
+	}
+
+	return w.Err()
+}
+
+// structWalk is a walk of ValidateStruct through a value.
+type structWalk struct {
+	*Validator
+
+	// walking are the pointers, maps and slices on the way from the struct
+	// to the value being walked. Not walking into them again keeps cyclic
+	// values from recursing forever, while values reached more than once
+	// without a cycle are still walked every time.
+	walking map[visit]bool
+}
+
+// visit is a pointer, map or slice walked into.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// enter marks val, a pointer, map or slice, as being walked into and adds
+// it to entered. It reports false if val is already being walked into.
+func (w *structWalk) enter(val reflect.Value, entered *[]visit) bool {
+	key := visit{ptr: val.Pointer(), typ: val.Type()}
+	if val.Kind() == reflect.Slice {
+		key.len = val.Len()
+	}
+
+	if w.walking[key] {
+		return false
+	}
+
+	w.walking[key] = true
+	*entered = append(*entered, key)
+
+	return true
+}
+
+// walk validates val, found at path, against fr, and walks into it.
+func (w *structWalk) walk(path string, val reflect.Value, fr *fieldRules) error {
+	var entered []visit
+	defer func() {
+		for _, key := range entered {
+			delete(w.walking, key)
+		}
+	}()
+
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			break
+		}
+
+		if val.Kind() == reflect.Ptr && !w.enter(val, &entered) {
+			return nil
+		}
+
+		val = val.Elem()
+	}
+
+	if isEmpty(val) {
+		if fr.required {
+			w.Fail(path, "required", "must be set")
+		}
+
+		return nil
+	}
+
+	if (val.Kind() == reflect.Slice || val.Kind() == reflect.Map) && !w.enter(val, &entered) {
+		return nil
+	}
+
+	switch val.Kind() {
+	case reflect.Struct:
+		return w.walkStruct(path, val)
+	case reflect.Slice, reflect.Array:
+		w.checkLimits(path, float64(val.Len()), fr, "must have at least %s elements", "must have at most %s elements")
+
+		elem := &fieldRules{rules: fr.rules}
+		for i := 0; i < val.Len(); i++ {
+			if err := w.walk(path+"["+strconv.Itoa(i)+"]", val.Index(i), elem); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		w.checkLimits(path, float64(val.Len()), fr, "must have at least %s elements", "must have at most %s elements")
+
+		keys := val.MapKeys()
+		names := make([]string, len(keys))
+		for i, k := range keys {
+			names[i] = fmt.Sprint(k.Interface())
+		}
+
+		order := make([]int, len(keys))
+		for i := range order {
+			order[i] = i
+		}
+
+		sort.Slice(order, func(i, j int) bool { return names[order[i]] < names[order[j]] })
+
+		elem := &fieldRules{rules: fr.rules}
+		for _, i := range order {
+			if err := w.walk(path+"["+names[i]+"]", val.MapIndex(keys[i]), elem); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		w.checkLimits(path, float64(utf8.RuneCountInString(val.String())), fr, "must be at least %s characters long", "must be at most %s characters long")
+		w.Check(path, val.String(), fr.rules...)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		w.checkLimits(path, float64(val.Int()), fr, "must be at least %s", "must be at most %s")
+		w.Check(path, strconv.FormatInt(val.Int(), 10), fr.rules...)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		w.checkLimits(path, float64(val.Uint()), fr, "must be at least %s", "must be at most %s")
+		w.Check(path, strconv.FormatUint(val.Uint(), 10), fr.rules...)
+	case reflect.Float32, reflect.Float64:
+		w.checkLimits(path, val.Float(), fr, "must be at least %s", "must be at most %s")
+		w.Check(path, strconv.FormatFloat(val.Float(), 'g', -1, 64), fr.rules...)
+	case reflect.Bool:
+		w.Check(path, strconv.FormatBool(val.Bool()), fr.rules...)
+	}
+
+	return nil
+}
+
+func (w *structWalk) walkStruct(path string, val reflect.Value) error {
+	t := val.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.PkgPath != "" || f.Tag.Get("sutils") == "-" {
+			continue
+		}
+
+		fieldPath := joinPath(path, fieldName(f))
+
+		fr, err := structFieldRules(t, i)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", fieldPath, err)
+		}
+
+		// The fields of embedded structs are named as if they were the
+		// struct's own.
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			fieldPath = path
+		}
+
+		if err := w.walk(fieldPath, val.Field(i), fr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkLimits checks n, the length or value of the field at path, against
+// the min and max rules of fr.
+func (v *Validator) checkLimits(path string, n float64, fr *fieldRules, tooSmall, tooLarge string) {
+	if fr.min != "" {
+		if min, _ := strconv.ParseFloat(fr.min, 64); n < min {
+			v.Fail(path, "min", fmt.Sprintf(tooSmall, fr.min))
+		}
+	}
+
+	if fr.max != "" {
+		if max, _ := strconv.ParseFloat(fr.max, 64); n > max {
+			v.Fail(path, "max", fmt.Sprintf(tooLarge, fr.max))
+		}
+	}
+}
+
+// isEmpty reports whether val is nil, has no elements or is its zero value,
+// the way Present treats empty strings.
+func isEmpty(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Interface:
+		return val.IsNil()
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return val.Len() == 0
+	case reflect.Struct:
+		return false
+	}
+
+	return val.IsZero()
+}
+
+func fieldName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+
+	return strings.ToLower(f.Name)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
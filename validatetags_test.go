@@ -0,0 +1,188 @@
+package sutils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testUser struct {
+	Name  string   `sutils:"required,max=16"`
+	Email string   `json:"mail" sutils:"email"`
+	Roles []string `sutils:"oneof=admin reader"`
+}
+
+type testDB struct {
+	Host  string            `sutils:"required,hostname"`
+	Port  int               `sutils:"min=1,max=65535"`
+	Users []testUser        `sutils:"min=1"`
+	Vars  map[string]string `sutils:"max=2,regex=^[a-z,]+$"`
+}
+
+type testConfig struct {
+	DB      testDB `json:"db"`
+	Backup  *testDB
+	Region  string `sutils:"even"`
+	Skipped string `sutils:"-"`
+	secret  string
+}
+
+func TestValidateStruct(t *testing.T) {
+	RegisterRule("even", func(param string) (Rule, error) {
+		return Rule{Name: "even", Check: func(value string) error {
+			if len(value)%2 != 0 {
+				return errors.New("must have an even length")
+			}
+
+			return nil
+		}}, nil
+	})
+
+	valid := testConfig{DB: testDB{
+		Host:  "db.example.com",
+		Port:  5432,
+		Users: []testUser{{Name: "bob", Email: "bob@example.com", Roles: []string{"admin"}}},
+		Vars:  map[string]string{"a": "x,y"},
+	}}
+
+	if err := ValidateStruct(&valid); err != nil {
+		t.Errorf("ValidateStruct errored out: %v", err)
+	}
+
+	invalid := testConfig{
+		DB: testDB{
+			Host: "db_1",
+			Port: 70000,
+			Users: []testUser{
+				{Name: "alice"},
+				{Name: "bob", Roles: []string{"admin", "root"}},
+				{Name: "", Email: "nope"},
+			},
+			Vars: map[string]string{"b": "X", "a": "ok", "c": "ok"},
+		},
+		Backup: &testDB{Host: "backup", Port: -1},
+		Region: "odd",
+	}
+
+	err := ValidateStruct(invalid)
+
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+
+	var got []string
+	for _, f := range verr.Fields {
+		got = append(got, f.Field+" "+f.Rule)
+	}
+
+	expected := []string{
+		"db.host hostname",
+		"db.port max",
+		"db.users[1].roles[1] oneof",
+		"db.users[2].name required",
+		"db.users[2].mail email",
+		"db.vars max",
+		"db.vars[b] regex",
+		"backup.port min",
+		"region even",
+	}
+
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("mismatch. Expected %v, got %v", expected, got)
+	}
+}
+
+func TestValidateStructBadTags(t *testing.T) {
+	tests := []interface{}{
+		struct {
+			A string `sutils:"nosuchrule"`
+		}{"x"},
+		struct {
+			A string `sutils:"max=many"`
+		}{"x"},
+		struct {
+			A string `sutils:"regex=("`
+		}{"x"},
+		"not a struct",
+	}
+
+	for _, test := range tests {
+		err := ValidateStruct(test)
+		if err == nil {
+			t.Errorf("expected an error for %#v", test)
+			continue
+		}
+
+		if _, ok := err.(*ValidationError); ok {
+			t.Errorf("expected a tag error for %#v, got %v", test, err)
+		}
+	}
+}
+
+type testNode struct {
+	Name  string `sutils:"required"`
+	Next  *testNode
+	Items []interface{}
+}
+
+func TestValidateStructCycles(t *testing.T) {
+	node := &testNode{Name: "a"}
+	node.Next = &testNode{Next: node}
+	node.Items = []interface{}{node, nil}
+	node.Items[1] = node.Items
+
+	err := ValidateStruct(node)
+
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+
+	expected := []FieldError{{Field: "next.name", Rule: "required", Message: "must be set"}}
+	if !reflect.DeepEqual(expected, verr.Fields) {
+		t.Errorf("mismatch. Expected %v, got %v", expected, verr.Fields)
+	}
+}
+
+func TestValidateStructSharedValues(t *testing.T) {
+	s := "toolong"
+	shared := struct {
+		A *string `sutils:""`
+		B *string `sutils:"max=3"`
+	}{&s, &s}
+
+	err := ValidateStruct(shared)
+
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+
+	if len(verr.Fields) != 1 || verr.Fields[0].Field != "b" || verr.Fields[0].Rule != "max" {
+		t.Errorf("expected b to fail max, got %v", verr.Fields)
+	}
+}
+
+func TestValidateStructParsesTagsOnce(t *testing.T) {
+	parsed := 0
+	RegisterRule("counted", func(param string) (Rule, error) {
+		parsed++
+		return Rule{Name: "counted", Check: func(string) error { return nil }}, nil
+	})
+
+	type item struct {
+		A string `sutils:"counted"`
+	}
+
+	items := struct{ Items []item }{make([]item, 10)}
+	for i := 0; i < 3; i++ {
+		if err := ValidateStruct(items); err != nil {
+			t.Fatalf("ValidateStruct errored out: %v", err)
+		}
+	}
+
+	if parsed != 1 {
+		t.Errorf("expected the tag to be parsed once, it was parsed %d times", parsed)
+	}
+}